@@ -0,0 +1,207 @@
+package ticketguru
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ADFNode defines a node of an Atlassian Document Format tree, as returned by the v3 REST API of Jira Cloud.
+type ADFNode struct {
+	Type    string                 `json:"type"`
+	Text    string                 `json:"text,omitempty"`
+	Attrs   map[string]interface{} `json:"attrs,omitempty"`
+	Content []ADFNode              `json:"content,omitempty"`
+}
+
+// Structure holds structural hints about a rich text field, which are lost when converting it to plain text.
+type Structure struct {
+	CodeBlocks   int `json:"codeBlocks,omitempty"`
+	OrderedLists int `json:"orderedLists,omitempty"`
+	BulletLists  int `json:"bulletLists,omitempty"`
+	Panels       int `json:"panels,omitempty"`
+	Tables       int `json:"tables,omitempty"`
+}
+
+// UnmarshalJSON decodes the fields of a Jira ticket, converting an ADF description into plain text.
+func (f *Fields) UnmarshalJSON(b []byte) error {
+	type fields Fields
+	aux := struct {
+		*fields
+		Description json.RawMessage `json:"description,omitempty"`
+	}{
+		fields: (*fields)(f),
+	}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	description, structure, err := DecodeRichText(aux.Description)
+	if err != nil {
+		return fmt.Errorf("could not decode description: %v", err)
+	}
+	f.Description = description
+	if structure != (Structure{}) {
+		f.DescriptionStructure = structure
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes a Jira comment, converting an ADF body into plain text.
+func (c *Comment) UnmarshalJSON(b []byte) error {
+	type comment Comment
+	aux := struct {
+		*comment
+		Body json.RawMessage `json:"body,omitempty"`
+	}{
+		comment: (*comment)(c),
+	}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	body, structure, err := DecodeRichText(aux.Body)
+	if err != nil {
+		return fmt.Errorf("could not decode comment %s: %v", c.ID, err)
+	}
+	c.Body = body
+	if structure != (Structure{}) {
+		c.Structure = structure
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes a Jira worklog, converting an ADF comment into plain text.
+func (w *Worklog) UnmarshalJSON(b []byte) error {
+	type worklog Worklog
+	aux := struct {
+		*worklog
+		Comment json.RawMessage `json:"comment,omitempty"`
+	}{
+		worklog: (*worklog)(w),
+	}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	comment, _, err := DecodeRichText(aux.Comment)
+	if err != nil {
+		return fmt.Errorf("could not decode worklog %s: %v", w.ID, err)
+	}
+	w.Comment = comment
+	return nil
+}
+
+// DecodeRichText decodes a rich text field that is either a plain string (v2 API, wiki markup) or an
+// ADF document (v3 API) into plain text along with its structural hints.
+func DecodeRichText(raw json.RawMessage) (string, Structure, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return "", Structure{}, nil
+	}
+	if raw[0] == '"' {
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, Structure{}, err
+	}
+	var doc ADFNode
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return "", Structure{}, err
+	}
+	text, structure := ADFToText(doc)
+	return text, structure, nil
+}
+
+// ADFToText converts an ADF document into plain text. Block nodes are rendered with the wiki markup the v2 API
+// returns for them (e.g. "* " list items, {code} blocks), so the analyses behave the same on v2 and v3 data.
+func ADFToText(doc ADFNode) (string, Structure) {
+	var builder strings.Builder
+	var structure Structure
+	renderADF(&builder, &structure, doc, "")
+	return strings.TrimRight(builder.String(), "\n"), structure
+}
+
+// renderADF writes the text of a node and its children into builder; prefix holds the list markers of the
+// enclosing list items.
+func renderADF(builder *strings.Builder, structure *Structure, node ADFNode, prefix string) {
+	switch node.Type {
+	case "text":
+		builder.WriteString(node.Text)
+	case "hardBreak":
+		builder.WriteRune('\n')
+	case "mention", "emoji", "status", "date":
+		if text, ok := node.Attrs["text"].(string); ok {
+			builder.WriteString(text)
+		} else if shortName, ok := node.Attrs["shortName"].(string); ok {
+			builder.WriteString(shortName)
+		}
+	case "inlineCard", "blockCard", "embedCard":
+		if url, ok := node.Attrs["url"].(string); ok {
+			builder.WriteString(url)
+		}
+	case "paragraph", "heading":
+		renderADFChildren(builder, structure, node, prefix)
+		builder.WriteRune('\n')
+	case "codeBlock":
+		structure.CodeBlocks++
+		builder.WriteString("{code}\n")
+		renderADFChildren(builder, structure, node, prefix)
+		builder.WriteString("\n{code}\n")
+	case "panel":
+		structure.Panels++
+		builder.WriteString("{panel}\n")
+		renderADFChildren(builder, structure, node, prefix)
+		builder.WriteString("{panel}\n")
+	case "bulletList":
+		structure.BulletLists++
+		renderADFList(builder, structure, node, prefix+"*")
+	case "orderedList":
+		structure.OrderedLists++
+		renderADFList(builder, structure, node, prefix+"#")
+	case "table":
+		structure.Tables++
+		renderADFChildren(builder, structure, node, prefix)
+	case "tableRow":
+		builder.WriteRune('|')
+		for _, cell := range node.Content {
+			var cellBuilder strings.Builder
+			renderADFChildren(&cellBuilder, structure, cell, prefix)
+			builder.WriteString(strings.Replace(strings.TrimSpace(cellBuilder.String()), "\n", " ", -1))
+			builder.WriteRune('|')
+		}
+		builder.WriteRune('\n')
+	case "rule":
+		builder.WriteString("----\n")
+	default:
+		renderADFChildren(builder, structure, node, prefix)
+	}
+}
+
+// renderADFChildren renders every child of a node in order.
+func renderADFChildren(builder *strings.Builder, structure *Structure, node ADFNode, prefix string) {
+	for _, child := range node.Content {
+		renderADF(builder, structure, child, prefix)
+	}
+}
+
+// renderADFList renders the items of a list on separate lines, each starting with the list markers.
+func renderADFList(builder *strings.Builder, structure *Structure, node ADFNode, markers string) {
+	builder.WriteRune('\n')
+	for _, item := range node.Content {
+		var itemBuilder strings.Builder
+		for _, child := range item.Content {
+			if child.Type == "bulletList" || child.Type == "orderedList" {
+				if text := strings.TrimSpace(itemBuilder.String()); text != "" {
+					builder.WriteString(markers + " " + text + "\n")
+				}
+				itemBuilder.Reset()
+				var nested strings.Builder
+				renderADF(&nested, structure, child, markers)
+				builder.WriteString(strings.TrimLeft(nested.String(), "\n"))
+				continue
+			}
+			renderADF(&itemBuilder, structure, child, markers)
+		}
+		if text := strings.TrimSpace(itemBuilder.String()); text != "" {
+			builder.WriteString(markers + " " + text + "\n")
+		}
+	}
+}
@@ -3,12 +3,16 @@ package analyze
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/nclandrei/ticketguru/jira"
 )
 
+// sessionGap defines the longest pause between two worklogs belonging to the same work session.
+const sessionGap = 4 * time.Hour
+
 // TicketAnalysis defines a function that analyzes a variadic number of tickets and updates
 // their metrics fields accordingly.
 type TicketAnalysis func(...jira.JiraIssue)
@@ -23,8 +27,7 @@ func TimesToClose(tickets ...jira.JiraIssue) {
 		var closed bool
 		for _, history := range tickets[i].Changelog.Histories {
 			for _, item := range history.Items {
-				if item.Field == "status" && (item.ToString == "Closed" || item.ToString == "Resolved" ||
-					item.ToString == "Done" || item.ToString == "Completed" || item.ToString == "Fixed") {
+				if item.Field == "status" && jira.IsClosingStatus(item.ToString) {
 					tickets[i].TimeToClose = calculateTimeDifference(history.Created, tickets[i].Fields.Created)
 					count++
 					closed = true
@@ -42,6 +45,191 @@ func TimesToClose(tickets ...jira.JiraIssue) {
 	fmt.Println(count)
 }
 
+// BlockedDurations computes how many hours a variadic number of tickets spent blocked, either by any
+// "is blocked by" link or in a Blocked status, until they were closed (or until now for open tickets).
+// Overlapping blocked periods are only counted once.
+func BlockedDurations(tickets ...jira.JiraIssue) {
+	now := time.Now()
+	for i := range tickets {
+		if !isTicketHighPriority(tickets[i]) {
+			continue
+		}
+		end := now
+		active := make(map[string]bool)
+		var blockedSince time.Time
+		var blocked time.Duration
+		for _, history := range tickets[i].Changelog.Histories {
+			created := time.Time(history.Created)
+			for _, item := range history.Items {
+				var cause string
+				var starts bool
+				switch {
+				case item.Field == "Link" && strings.Contains(item.ToString, "blocked by"):
+					cause, starts = item.To, true
+				case item.Field == "Link" && strings.Contains(item.FromString, "blocked by"):
+					cause = item.From
+				case item.Field == "status" && strings.EqualFold(item.ToString, "Blocked"):
+					cause, starts = "status", true
+				case item.Field == "status" && strings.EqualFold(item.FromString, "Blocked"):
+					cause = "status"
+				}
+				if item.Field == "status" && jira.IsClosingStatus(item.ToString) && end == now {
+					end = created
+				}
+				if cause == "" {
+					continue
+				}
+				if starts {
+					if len(active) == 0 {
+						blockedSince = created
+					}
+					active[cause] = true
+				} else if active[cause] {
+					delete(active, cause)
+					if len(active) == 0 {
+						blocked += created.Sub(blockedSince)
+					}
+				}
+			}
+		}
+		if tickets[i].Fields.Status.Name == "Open" {
+			end = now
+		}
+		if len(active) > 0 && end.After(blockedSince) {
+			blocked += end.Sub(blockedSince)
+		}
+		tickets[i].BlockedHours = blocked.Hours()
+	}
+}
+
+// Efforts derives effort metrics from the worklogs of a variadic number of tickets: the number of people
+// logging work, the number of work sessions and the ratio of logged time to calendar time until closing.
+func Efforts(tickets ...jira.JiraIssue) {
+	for i := range tickets {
+		if !isTicketHighPriority(tickets[i]) {
+			continue
+		}
+		worklogs := tickets[i].Fields.Worklogs.Worklogs
+		if len(worklogs) == 0 {
+			tickets[i].Effort = jira.Effort{}
+			continue
+		}
+
+		contributors := make(map[string]bool)
+		var logged time.Duration
+		for _, w := range worklogs {
+			contributors[authorID(w.Author)] = true
+			logged += time.Duration(w.TimeSpentSeconds) * time.Second
+		}
+
+		var ratio float64
+		if closed, ok := closingTime(tickets[i]); ok {
+			calendar := closed.Sub(time.Time(tickets[i].Fields.Created))
+			if calendar > 0 {
+				ratio = logged.Hours() / calendar.Hours()
+			}
+		}
+
+		tickets[i].Effort = jira.Effort{
+			Contributors: len(contributors),
+			Sessions:     workSessions(worklogs),
+			LoggedHours:  logged.Hours(),
+			Ratio:        ratio,
+			HasEffort:    true,
+		}
+	}
+}
+
+// SprintMetrics derives sprint metrics from the sprints of a variadic number of tickets: the number of sprints,
+// the number of times the ticket was carried over to a later sprint and the hours spanned by its sprints.
+func SprintMetrics(tickets ...jira.JiraIssue) {
+	for i := range tickets {
+		if !isTicketHighPriority(tickets[i]) {
+			continue
+		}
+		sprints := tickets[i].Sprints
+		if len(sprints) == 0 {
+			tickets[i].SprintMetrics = jira.SprintMetrics{}
+			continue
+		}
+
+		var carryOvers int
+		for _, sprint := range sprints[:len(sprints)-1] {
+			if sprint.State == "closed" {
+				carryOvers++
+			}
+		}
+
+		var hours float64
+		start := time.Time(sprints[0].StartDate)
+		end := sprintEnd(sprints[len(sprints)-1])
+		if !start.IsZero() && end.After(start) {
+			hours = end.Sub(start).Hours()
+		}
+
+		tickets[i].SprintMetrics = jira.SprintMetrics{
+			Count:      len(sprints),
+			CarryOvers: carryOvers,
+			Hours:      hours,
+			HasSprints: true,
+		}
+	}
+}
+
+// sprintEnd returns when a sprint was completed or, if it is still running, when it is planned to end.
+func sprintEnd(sprint jira.Sprint) time.Time {
+	if completed := time.Time(sprint.CompleteDate); !completed.IsZero() {
+		return completed
+	}
+	return time.Time(sprint.EndDate)
+}
+
+// workSessions counts the runs of worklogs where each worklog starts at most sessionGap after the previous
+// ones ended, regardless of who logged them.
+func workSessions(worklogs []jira.Worklog) int {
+	sorted := make([]jira.Worklog, len(worklogs))
+	copy(sorted, worklogs)
+	sort.Slice(sorted, func(i, j int) bool {
+		return time.Time(sorted[i].Started).Before(time.Time(sorted[j].Started))
+	})
+	var sessions int
+	var sessionEnd time.Time
+	for _, w := range sorted {
+		start := time.Time(w.Started)
+		end := start.Add(time.Duration(w.TimeSpentSeconds) * time.Second)
+		if sessions == 0 || start.Sub(sessionEnd) > sessionGap {
+			sessions++
+		}
+		if end.After(sessionEnd) {
+			sessionEnd = end
+		}
+	}
+	return sessions
+}
+
+// authorID returns the most stable identifier available for an author.
+func authorID(a jira.Author) string {
+	if a.AccountID != "" {
+		return a.AccountID
+	}
+	if a.Name != "" {
+		return a.Name
+	}
+	return a.DisplayName
+}
+
+// closingTime returns when a ticket was first moved into a closing status.
+func closingTime(ticket jira.JiraIssue) (time.Time, bool) {
+	for _, history := range ticket.Changelog.Histories {
+		for _, item := range history.Items {
+			if item.Field == "status" && jira.IsClosingStatus(item.ToString) {
+				return time.Time(history.Created), true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
 // FieldsComplexity counts the number of words in summary and description for a variadic number of tickets.
 func FieldsComplexity(tickets ...jira.JiraIssue) {
 	for i := range tickets {
@@ -102,20 +290,21 @@ func fileExtension(f string) string {
 }
 
 // StepsToReproduce returns whether a variadic number of tickets have steps to reproduce or not inside
-// summary, description or any of the comments.
+// summary, description or any of the comments. Ordered lists of ADF documents also count as steps.
 func StepsToReproduce(tickets ...jira.JiraIssue) {
 	expr := `(\n(\s*)\*(.*)){2,}`
 	for i := range tickets {
 		if !isTicketHighPriority(tickets[i]) {
 			continue
 		}
-		contains := containsRegex(tickets[i].Fields.Description, expr)
+		contains := containsRegex(tickets[i].Fields.Description, expr) ||
+			tickets[i].Fields.DescriptionStructure.OrderedLists > 0
 		if contains {
 			tickets[i].HasStepsToReproduce = true
 			continue
 		}
 		for _, comment := range tickets[i].Fields.Comments.Comments {
-			contains = containsRegex(comment.Body, expr)
+			contains = containsRegex(comment.Body, expr) || comment.Structure.OrderedLists > 0
 			if contains {
 				tickets[i].HasStepsToReproduce = true
 				break
@@ -24,7 +24,7 @@ const (
 
 // Scorer defines an interface for holding the different types of language scorers available.
 type Scorer interface {
-	Scores(...jira.JiraIssue) error
+	Scores(context.Context, ...jira.JiraIssue) error
 }
 
 // BingClient defines a new Bing Spell Check client.
@@ -65,8 +65,9 @@ func NewBingClient(key string) *BingClient {
 	}
 }
 
-// Scores returns the grammar correctness scores for all issues given as input parameters.
-func (client *BingClient) Scores(issues ...jira.JiraIssue) error {
+// Scores returns the grammar correctness scores for all issues given as input parameters. Once ctx is
+// cancelled, in-flight requests are aborted and no further batch is started.
+func (client *BingClient) Scores(ctx context.Context, issues ...jira.JiraIssue) error {
 	errCh := make(chan error, len(issues))
 	var rateLimit int
 	if bingRateLimit > len(issues) {
@@ -74,8 +75,11 @@ func (client *BingClient) Scores(issues ...jira.JiraIssue) error {
 	} else {
 		rateLimit = bingRateLimit
 	}
-	for i := 0; i < len(issues); i += rateLimit {
+	var launched int
+	var cancelErr error
+	for i := 0; i < len(issues) && cancelErr == nil; i += rateLimit {
 		for j := range issues[i:(i + rateLimit)] {
+			launched++
 			go func(i, j int) {
 				if issues[i+j].GrammarCorrectness.HasScore {
 					errCh <- nil
@@ -86,9 +90,15 @@ func (client *BingClient) Scores(issues ...jira.JiraIssue) error {
 					errCh <- err
 					return
 				}
+				// Tickets without text cannot be scored and are left unscored without querying Bing.
+				if strings.TrimSpace(strToAnalyze) == "" {
+					errCh <- nil
+					return
+				}
 				values := url.Values{}
 				values.Set("Text", strToAnalyze)
-				req, err := http.NewRequest(
+				req, err := http.NewRequestWithContext(
+					ctx,
 					"POST",
 					bingAPIPath,
 					strings.NewReader(values.Encode()),
@@ -121,10 +131,13 @@ func (client *BingClient) Scores(issues ...jira.JiraIssue) error {
 				errCh <- nil
 			}(i, j)
 		}
-		time.Sleep(1 * time.Second)
+		cancelErr = sleep(ctx, 1*time.Second)
 	}
 	var strBuilder strings.Builder
-	for i := 0; i < len(issues); i++ {
+	if cancelErr != nil {
+		strBuilder.WriteString("grammar scoring interrupted: " + cancelErr.Error() + "\n")
+	}
+	for i := 0; i < launched; i++ {
 		if err := <-errCh; err != nil {
 			strBuilder.WriteString("error while retrieving grammar scores: ")
 			strBuilder.WriteString(err.Error())
@@ -140,7 +153,6 @@ func (client *BingClient) Scores(issues ...jira.JiraIssue) error {
 // SentimentClient defines a GCP Language Client
 type SentimentClient struct {
 	*language.Client
-	ctx context.Context
 }
 
 // NewSentimentClient returns a new language clients alogn with its context
@@ -151,12 +163,12 @@ func NewSentimentClient(ctx context.Context) (*SentimentClient, error) {
 	}
 	return &SentimentClient{
 		Client: client,
-		ctx:    ctx,
 	}, nil
 }
 
-// Scores calculates the sentiment score for an issue's comments after querying GCP.
-func (client *SentimentClient) Scores(issues ...jira.JiraIssue) error {
+// Scores calculates the sentiment score for an issue's comments after querying GCP. Once ctx is
+// cancelled, in-flight requests are aborted and no further batch is started.
+func (client *SentimentClient) Scores(ctx context.Context, issues ...jira.JiraIssue) error {
 	errCh := make(chan error, len(issues))
 	var rateLimit int
 	if gcpRateLimit > len(issues) {
@@ -165,18 +177,26 @@ func (client *SentimentClient) Scores(issues ...jira.JiraIssue) error {
 		rateLimit = gcpRateLimit
 	}
 	highBound := rateLimit
-	for i := 0; i < len(issues); i += rateLimit {
+	var launched int
+	var cancelErr error
+	for i := 0; i < len(issues) && cancelErr == nil; i += rateLimit {
 		if i+highBound > len(issues) {
 			highBound = len(issues) % rateLimit
 		}
 		for j := range issues[i:(i + highBound)] {
+			launched++
 			go func(i, j int) {
 				if issues[i+j].Sentiment.HasScore {
 					errCh <- nil
 					return
 				}
 				concatComm := concatComments(issues[i+j])
-				sentiment, err := client.AnalyzeSentiment(client.ctx, &languagepb.AnalyzeSentimentRequest{
+				// Tickets without comments cannot be scored and are left unscored without querying GCP.
+				if strings.TrimSpace(concatComm) == "" {
+					errCh <- nil
+					return
+				}
+				sentiment, err := client.AnalyzeSentiment(ctx, &languagepb.AnalyzeSentimentRequest{
 					Document: &languagepb.Document{
 						Source: &languagepb.Document_Content{
 							Content: concatComm,
@@ -194,10 +214,13 @@ func (client *SentimentClient) Scores(issues ...jira.JiraIssue) error {
 				errCh <- nil
 			}(i, j)
 		}
-		time.Sleep(1 * time.Minute)
+		cancelErr = sleep(ctx, 1*time.Minute)
 	}
 	var strBuilder strings.Builder
-	for i := 0; i < len(issues); i++ {
+	if cancelErr != nil {
+		strBuilder.WriteString("sentiment scoring interrupted: " + cancelErr.Error() + "\n")
+	}
+	for i := 0; i < launched; i++ {
 		if err := <-errCh; err != nil {
 			strBuilder.WriteString("error while retrieving sentiment scores: ")
 			strBuilder.WriteString(err.Error())
@@ -211,17 +234,31 @@ func (client *SentimentClient) Scores(issues ...jira.JiraIssue) error {
 }
 
 // MultipleScores takes multiple issues and scorers and returns a map for each scorer to its corresponding scores.
-func MultipleScores(issues []jira.JiraIssue, scorers ...Scorer) error {
+// It waits for every scorer to return, so no issue is still being updated when it returns.
+func MultipleScores(ctx context.Context, issues []jira.JiraIssue, scorers ...Scorer) error {
 	errCh := make(chan error, len(scorers))
 	for i := range scorers {
 		go func(i int) {
-			errCh <- scorers[i].Scores(issues...)
+			errCh <- scorers[i].Scores(ctx, issues...)
 		}(i)
 	}
+	var firstErr error
 	for i := 0; i < len(scorers); i++ {
-		if err := <-errCh; err != nil {
-			return err
+		if err := <-errCh; err != nil && firstErr == nil {
+			firstErr = err
 		}
 	}
-	return nil
+	return firstErr
+}
+
+// sleep waits for d, returning early with the error of ctx if it is cancelled first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
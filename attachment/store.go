@@ -0,0 +1,178 @@
+package attachment
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/nclandrei/ticketguru/jira"
+)
+
+// Doer defines an HTTP client able to execute requests; tracker clients embedding *http.Client satisfy it,
+// so downloads go through their authentication.
+type Doer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Store defines a content-addressed directory of attachment bodies, keyed by their SHA-256 hash.
+type Store struct {
+	Dir     string
+	MaxSize int64
+	Workers int
+	Client  Doer
+}
+
+// NewStore returns a new Store rooted at dir, creating the directory if needed.
+func NewStore(dir string, maxSize int64, workers int, client Doer) (*Store, error) {
+	if workers < 1 {
+		return nil, fmt.Errorf("number of workers must be at least 1, got %d", workers)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Store{
+		Dir:     dir,
+		MaxSize: maxSize,
+		Workers: workers,
+		Client:  client,
+	}, nil
+}
+
+// Path returns the path an attachment body with the given hash is stored at.
+func (s *Store) Path(hash string) string {
+	return filepath.Join(s.Dir, hash[:2], hash)
+}
+
+// Download fetches the bodies of every attachment of a variadic number of tickets not downloaded yet, recording
+// their hash and real size. It returns the tickets that were updated; attachments over the size cap are skipped.
+// Once ctx is cancelled, in-flight downloads are aborted and the tickets updated so far are returned.
+func (s *Store) Download(ctx context.Context, tickets ...jira.JiraIssue) ([]jira.JiraIssue, error) {
+	type job struct {
+		ticket, attachment int
+	}
+	jobs := make(chan job)
+	errCh := make(chan error, len(tickets))
+	updated := make(map[int]bool)
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+
+	for w := 0; w < s.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				a := &tickets[j.ticket].Fields.Attachments[j.attachment]
+				hash, size, err := s.fetch(ctx, a.Content)
+				if err != nil {
+					errCh <- fmt.Errorf("could not download attachment %s of %s: %v", a.ID, tickets[j.ticket].Key, err)
+					continue
+				}
+				if hash == "" {
+					continue
+				}
+				lock.Lock()
+				a.SHA256 = hash
+				a.ContentSize = size
+				updated[j.ticket] = true
+				lock.Unlock()
+			}
+		}()
+	}
+
+	var errs []error
+	done := make(chan struct{})
+	go func() {
+		for err := range errCh {
+			errs = append(errs, err)
+		}
+		close(done)
+	}()
+
+queue:
+	for i := range tickets {
+		for k, a := range tickets[i].Fields.Attachments {
+			if a.SHA256 != "" || a.Content == "" || (s.MaxSize > 0 && int64(a.Size) > s.MaxSize) {
+				continue
+			}
+			select {
+			case jobs <- job{i, k}:
+			case <-ctx.Done():
+				break queue
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errCh)
+	<-done
+
+	var result []jira.JiraIssue
+	for i := range tickets {
+		if updated[i] {
+			result = append(result, tickets[i])
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	if len(errs) > 0 {
+		return result, fmt.Errorf("%d attachments failed to download, first error: %v", len(errs), errs[0])
+	}
+	return result, nil
+}
+
+// fetch downloads a single attachment body into the store, returning its hash and size. An empty hash
+// is returned when the body exceeds the size cap.
+func (s *Store) fetch(ctx context.Context, url string) (string, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("status code different than 200: %v", resp.Status)
+	}
+
+	tmp, err := ioutil.TempFile(s.Dir, "download-")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	body := io.Reader(resp.Body)
+	if s.MaxSize > 0 {
+		body = io.LimitReader(resp.Body, s.MaxSize+1)
+	}
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	if s.MaxSize > 0 && size > s.MaxSize {
+		return "", 0, nil
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	path := s.Path(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, size, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", 0, err
+	}
+	return hash, size, os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,209 @@
+package backup
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nclandrei/ticketguru/jira"
+)
+
+// csvTimeFormats lists the date formats used by CSV exports, depending on the date settings of the instance.
+var csvTimeFormats = []string{"02/Jan/06 3:04 PM", "02/Jan/06 15:04", "2006-01-02 15:04", "2006-01-02 15:04:05"}
+
+// priorityIDs maps the names of the default Jira priorities onto their IDs, as CSV exports only hold names.
+var priorityIDs = map[string]string{
+	"blocker":  "1",
+	"highest":  "1",
+	"critical": "2",
+	"high":     "2",
+	"major":    "3",
+	"medium":   "3",
+	"minor":    "4",
+	"low":      "4",
+	"trivial":  "5",
+	"lowest":   "5",
+}
+
+// linkColumnExpr matches the header of an issue link column, capturing its direction and link type.
+var linkColumnExpr = regexp.MustCompile(`^(Inward|Outward) issue link \((.+)\)$`)
+
+// ReadCSV reads the issues of a CSV exported from the Jira issue navigator, mapping its columns onto
+// the fields of a ticket and passing each ticket to fn. Multi-valued fields (labels, comments, attachments,
+// links) span several columns with the same header. As for XML exports, the resolution date of resolved
+// issues is recorded as their closing transition. Parents given only by ID are resolved to their key once
+// the whole export has been read, so fn is only called after the last record.
+func ReadCSV(r io.Reader, fn func(jira.JiraIssue) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("could not read header: %v", err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	var issues []jira.JiraIssue
+	keys := make(map[string]string)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		issue, err := csvIssue(header, record)
+		if err != nil {
+			return err
+		}
+		if issue.ID != "" {
+			keys[issue.ID] = issue.Key
+		}
+		issues = append(issues, issue)
+	}
+	for _, issue := range issues {
+		if parent := issue.Fields.Parent; parent != nil && parent.Key == "" {
+			parent.Key = keys[parent.ID]
+		}
+		if err := fn(issue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// csvIssue converts a record of a CSV export into a ticket.
+func csvIssue(header, record []string) (jira.JiraIssue, error) {
+	var issue jira.JiraIssue
+	var resolved jira.Time
+	for i, column := range header {
+		if i >= len(record) || strings.TrimSpace(record[i]) == "" {
+			continue
+		}
+		value := record[i]
+		var err error
+		switch column {
+		case "Issue key":
+			issue.Key = value
+		case "Issue id":
+			issue.ID = value
+		case "Summary":
+			issue.Fields.Summary = value
+		case "Description":
+			issue.Fields.Description = value
+		case "Issue Type":
+			issue.Fields.Type.Name = value
+		case "Status":
+			issue.Fields.Status.Name = value
+		case "Priority":
+			issue.Fields.Priority = jira.Priority{ID: priorityIDs[strings.ToLower(value)], Name: value}
+		case "Labels":
+			issue.Fields.Labels = append(issue.Fields.Labels, value)
+		case "Created":
+			issue.Fields.Created, err = parseCSVTime(value)
+		case "Resolved":
+			resolved, err = parseCSVTime(value)
+		case "Due Date":
+			issue.Fields.DueDate, err = parseCSVTime(value)
+		case "Remaining Estimate":
+			issue.Fields.TimeEstimate, err = strconv.Atoi(value)
+		case "Time Spent":
+			issue.Fields.TimeSpent, err = strconv.Atoi(value)
+		case "Parent id", "Parent":
+			if issue.Fields.Parent == nil {
+				issue.Fields.Parent = &jira.LinkedIssue{}
+			}
+			if _, numErr := strconv.Atoi(value); numErr == nil {
+				issue.Fields.Parent.ID = value
+			} else {
+				issue.Fields.Parent.Key = value
+			}
+		case "Comment":
+			var comment jira.Comment
+			comment, err = csvComment(value)
+			issue.Fields.Comments.Comments = append(issue.Fields.Comments.Comments, comment)
+		case "Attachment":
+			var attachment jira.Attachment
+			attachment, err = csvAttachment(value)
+			issue.Fields.Attachments = append(issue.Fields.Attachments, attachment)
+		default:
+			match := linkColumnExpr.FindStringSubmatch(column)
+			if match == nil {
+				continue
+			}
+			link := jira.IssueLink{Type: jira.IssueLinkType{Name: match[2]}}
+			if match[1] == "Inward" {
+				link.InwardIssue = &jira.LinkedIssue{Key: value}
+			} else {
+				link.OutwardIssue = &jira.LinkedIssue{Key: value}
+			}
+			issue.Fields.IssueLinks = append(issue.Fields.IssueLinks, link)
+		}
+		if err != nil {
+			return jira.JiraIssue{}, fmt.Errorf("could not parse %s of %s: %v", column, issue.Key, err)
+		}
+	}
+	if issue.Key == "" {
+		return jira.JiraIssue{}, fmt.Errorf("record without issue key")
+	}
+
+	issue.Fields.Comments.Total = len(issue.Fields.Comments.Comments)
+	issue.Fields.Comments.MaxResults = issue.Fields.Comments.Total
+	if !time.Time(resolved).IsZero() {
+		recordResolution(&issue, resolved)
+	}
+	return issue, nil
+}
+
+// csvComment parses a comment column, formatted as "created;author;body".
+func csvComment(value string) (jira.Comment, error) {
+	parts := strings.SplitN(value, ";", 3)
+	if len(parts) < 3 {
+		return jira.Comment{Body: value}, nil
+	}
+	created, err := parseCSVTime(parts[0])
+	if err != nil {
+		return jira.Comment{}, err
+	}
+	return jira.Comment{
+		Body:    parts[2],
+		Author:  jira.Author{Name: parts[1]},
+		Created: created,
+		Updated: created,
+	}, nil
+}
+
+// csvAttachment parses an attachment column, formatted as "created;author;filename;url".
+func csvAttachment(value string) (jira.Attachment, error) {
+	parts := strings.SplitN(value, ";", 4)
+	if len(parts) < 4 {
+		return jira.Attachment{}, fmt.Errorf("malformed attachment %s", strconv.Quote(value))
+	}
+	created, err := parseCSVTime(parts[0])
+	if err != nil {
+		return jira.Attachment{}, err
+	}
+	return jira.Attachment{
+		Author:   jira.Author{Name: parts[1]},
+		Filename: parts[2],
+		Content:  parts[3],
+		Created:  created,
+	}, nil
+}
+
+// parseCSVTime parses a date of a CSV export.
+func parseCSVTime(s string) (jira.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range csvTimeFormats {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return jira.Time(t), nil
+		}
+	}
+	return jira.Time{}, fmt.Errorf("could not parse time %s", strconv.Quote(s))
+}
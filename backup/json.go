@@ -0,0 +1,76 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/nclandrei/ticketguru/jira"
+)
+
+// ReadJSON streams the issues of a Jira search response (or of a plain JSON array of issues), passing each
+// to fn without holding the whole response in memory. The raw values of the custom fields of a mapping
+// (logical name to field ID) are kept as they are when fetching from the API.
+func ReadJSON(r io.Reader, mapping map[string]string, fn func(jira.JiraIssue) error) error {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	switch token {
+	case json.Delim('['):
+		return readIssues(decoder, mapping, fn)
+	case json.Delim('{'):
+	default:
+		return fmt.Errorf("expected a search response or an array of issues, got %v", token)
+	}
+
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if key != "issues" {
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return err
+			}
+			continue
+		}
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if token != json.Delim('[') {
+			return fmt.Errorf("expected an array of issues, got %v", token)
+		}
+		if err := readIssues(decoder, mapping, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readIssues decodes the elements of an array of issues up to and including its closing bracket.
+func readIssues(decoder *json.Decoder, mapping map[string]string, fn func(jira.JiraIssue) error) error {
+	for decoder.More() {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return err
+		}
+		var issue jira.JiraIssue
+		if err := json.Unmarshal(raw, &issue); err != nil {
+			return fmt.Errorf("could not decode issue: %v", err)
+		}
+		if len(mapping) > 0 {
+			if err := jira.ExtractCustomFields(raw, &issue, mapping); err != nil {
+				return fmt.Errorf("could not extract custom fields of %s: %v", issue.Key, err)
+			}
+		}
+		if err := fn(issue); err != nil {
+			return err
+		}
+	}
+	_, err := decoder.Token()
+	return err
+}
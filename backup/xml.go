@@ -0,0 +1,299 @@
+package backup
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nclandrei/ticketguru/jira"
+)
+
+// xmlTimeFormats lists the date formats used by Jira XML exports.
+var xmlTimeFormats = []string{"Mon, 2 Jan 2006 15:04:05 -0700", time.RFC1123Z}
+
+// xmlItem defines an issue of a Jira XML export (the RSS feed of the issue navigator).
+type xmlItem struct {
+	Key         xmlNamed   `xml:"key"`
+	Summary     string     `xml:"summary"`
+	Description string     `xml:"description"`
+	Type        xmlNamed   `xml:"type"`
+	Priority    xmlNamed   `xml:"priority"`
+	Status      xmlNamed   `xml:"status"`
+	Labels      []string   `xml:"labels>label"`
+	Created     string     `xml:"created"`
+	Resolved    string     `xml:"resolved"`
+	Due         string     `xml:"due"`
+	Estimate    xmlSeconds `xml:"timeestimate"`
+	TimeSpent   xmlSeconds `xml:"timespent"`
+	Comments    []struct {
+		ID      string `xml:"id,attr"`
+		Author  string `xml:"author,attr"`
+		Created string `xml:"created,attr"`
+		Body    string `xml:",chardata"`
+	} `xml:"comments>comment"`
+	Attachments []struct {
+		ID      string `xml:"id,attr"`
+		Name    string `xml:"name,attr"`
+		Size    int    `xml:"size,attr"`
+		Author  string `xml:"author,attr"`
+		Created string `xml:"created,attr"`
+	} `xml:"attachments>attachment"`
+	LinkTypes []struct {
+		ID       string       `xml:"id,attr"`
+		Name     string       `xml:"name"`
+		Outwards xmlLinkGroup `xml:"outwardlinks"`
+		Inwards  xmlLinkGroup `xml:"inwardlinks"`
+	} `xml:"issuelinks>issuelinktype"`
+	Subtasks []xmlNamed `xml:"subtasks>subtask"`
+	Parent   *xmlNamed  `xml:"parent"`
+	Fields   []struct {
+		ID     string   `xml:"id,attr"`
+		Values []string `xml:"customfieldvalues>customfieldvalue"`
+	} `xml:"customfields>customfield"`
+}
+
+// xmlNamed defines an element holding a name (or key) as text and an ID as attribute.
+type xmlNamed struct {
+	ID   string `xml:"id,attr"`
+	Name string `xml:",chardata"`
+}
+
+// xmlSeconds defines a duration element holding its value in seconds as attribute.
+type xmlSeconds struct {
+	Seconds int `xml:"seconds,attr"`
+}
+
+// xmlLinkGroup defines the links of a type in one direction.
+type xmlLinkGroup struct {
+	Description string     `xml:"description,attr"`
+	Issues      []xmlNamed `xml:"issuelink>issuekey"`
+}
+
+// ReadXML streams the issues of a Jira XML export, converting each into a ticket and passing it to fn.
+// Exports hold no changelog, so the resolution date of closed issues is recorded as their closing transition.
+// The values of the custom fields of a mapping (logical name to field ID) are kept as JSON strings.
+func ReadXML(r io.Reader, mapping map[string]string, fn func(jira.JiraIssue) error) error {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "item" {
+			continue
+		}
+		var item xmlItem
+		if err := decoder.DecodeElement(&item, &start); err != nil {
+			return fmt.Errorf("could not decode issue: %v", err)
+		}
+		issue, err := item.toIssue(mapping)
+		if err != nil {
+			return fmt.Errorf("could not convert issue %s: %v", item.Key.Name, err)
+		}
+		if err := fn(issue); err != nil {
+			return err
+		}
+	}
+}
+
+// toIssue converts an exported issue into a ticket.
+func (item xmlItem) toIssue(mapping map[string]string) (jira.JiraIssue, error) {
+	created, err := parseXMLTime(item.Created)
+	if err != nil {
+		return jira.JiraIssue{}, err
+	}
+	issue := jira.JiraIssue{
+		Key: item.Key.Name,
+		ID:  item.Key.ID,
+		Fields: jira.Fields{
+			Summary:      item.Summary,
+			Description:  htmlToText(item.Description),
+			TimeEstimate: item.Estimate.Seconds,
+			TimeSpent:    item.TimeSpent.Seconds,
+			Created:      created,
+			Status:       jira.Status{ID: item.Status.ID, Name: item.Status.Name},
+			Priority:     jira.Priority{ID: item.Priority.ID, Name: item.Priority.Name},
+			Type:         jira.Type{ID: item.Type.ID, Name: item.Type.Name},
+			Labels:       item.Labels,
+		},
+	}
+	if item.Due != "" {
+		if issue.Fields.DueDate, err = parseXMLTime(item.Due); err != nil {
+			return jira.JiraIssue{}, err
+		}
+	}
+
+	for _, c := range item.Comments {
+		commentCreated, err := parseXMLTime(c.Created)
+		if err != nil {
+			return jira.JiraIssue{}, err
+		}
+		issue.Fields.Comments.Comments = append(issue.Fields.Comments.Comments, jira.Comment{
+			ID:      c.ID,
+			Body:    htmlToText(c.Body),
+			Author:  jira.Author{Name: c.Author},
+			Created: commentCreated,
+			Updated: commentCreated,
+		})
+	}
+	issue.Fields.Comments.Total = len(issue.Fields.Comments.Comments)
+	issue.Fields.Comments.MaxResults = issue.Fields.Comments.Total
+
+	for _, a := range item.Attachments {
+		attachmentCreated, err := parseXMLTime(a.Created)
+		if err != nil {
+			return jira.JiraIssue{}, err
+		}
+		issue.Fields.Attachments = append(issue.Fields.Attachments, jira.Attachment{
+			ID:       a.ID,
+			Filename: a.Name,
+			Size:     a.Size,
+			Author:   jira.Author{Name: a.Author},
+			Created:  attachmentCreated,
+		})
+	}
+
+	for _, linkType := range item.LinkTypes {
+		t := jira.IssueLinkType{
+			ID:      linkType.ID,
+			Name:    linkType.Name,
+			Inward:  linkType.Inwards.Description,
+			Outward: linkType.Outwards.Description,
+		}
+		for _, linked := range linkType.Outwards.Issues {
+			issue.Fields.IssueLinks = append(issue.Fields.IssueLinks, jira.IssueLink{
+				Type:         t,
+				OutwardIssue: &jira.LinkedIssue{ID: linked.ID, Key: linked.Name},
+			})
+		}
+		for _, linked := range linkType.Inwards.Issues {
+			issue.Fields.IssueLinks = append(issue.Fields.IssueLinks, jira.IssueLink{
+				Type:        t,
+				InwardIssue: &jira.LinkedIssue{ID: linked.ID, Key: linked.Name},
+			})
+		}
+	}
+	for _, subtask := range item.Subtasks {
+		issue.Fields.Subtasks = append(issue.Fields.Subtasks, jira.LinkedIssue{ID: subtask.ID, Key: subtask.Name})
+	}
+	if item.Parent != nil {
+		issue.Fields.Parent = &jira.LinkedIssue{ID: item.Parent.ID, Key: item.Parent.Name}
+	}
+
+	if item.Resolved != "" {
+		resolved, err := parseXMLTime(item.Resolved)
+		if err != nil {
+			return jira.JiraIssue{}, err
+		}
+		recordResolution(&issue, resolved)
+	}
+
+	for name, id := range mapping {
+		for _, field := range item.Fields {
+			if field.ID != id || len(field.Values) == 0 {
+				continue
+			}
+			var value interface{} = field.Values
+			if len(field.Values) == 1 {
+				value = field.Values[0]
+			}
+			raw, err := json.Marshal(value)
+			if err != nil {
+				return jira.JiraIssue{}, err
+			}
+			if issue.CustomFields == nil {
+				issue.CustomFields = make(map[string]json.RawMessage)
+			}
+			issue.CustomFields[name] = raw
+		}
+	}
+	return issue, nil
+}
+
+// recordResolution records the resolution date of a closed issue as its closing transition, for exports
+// holding no changelog.
+func recordResolution(issue *jira.JiraIssue, resolved jira.Time) {
+	if !jira.IsClosingStatus(issue.Fields.Status.Name) {
+		return
+	}
+	issue.Changelog.Histories = []jira.ChangelogHistory{{
+		Created: resolved,
+		Items: []jira.ChangelogHistoryItem{{
+			Field:    "status",
+			To:       issue.Fields.Status.ID,
+			ToString: issue.Fields.Status.Name,
+		}},
+	}}
+	issue.Changelog.Total = 1
+	issue.Changelog.MaxResults = 1
+}
+
+// parseXMLTime parses a date of a Jira XML export.
+func parseXMLTime(s string) (jira.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range xmlTimeFormats {
+		if t, err := time.Parse(layout, s); err == nil {
+			return jira.Time(t), nil
+		}
+	}
+	return jira.Time{}, fmt.Errorf("could not parse time %s", strconv.Quote(s))
+}
+
+// tagExpr matches an HTML tag, capturing whether it is a closing tag and its name.
+var tagExpr = regexp.MustCompile(`<(/?)([a-zA-Z0-9]+)[^>]*>`)
+
+// htmlToText converts the HTML rendering of a rich text field back into text resembling its wiki markup
+// (e.g. "* " list items, {code} blocks), so the analyses behave the same as on data fetched from the API.
+func htmlToText(s string) string {
+	var builder strings.Builder
+	var markers []string
+	last := 0
+	for _, m := range tagExpr.FindAllStringSubmatchIndex(s, -1) {
+		builder.WriteString(html.UnescapeString(s[last:m[0]]))
+		last = m[1]
+		closing := s[m[2]:m[3]] == "/"
+		tag := strings.ToLower(s[m[4]:m[5]])
+		switch tag {
+		case "br":
+			builder.WriteRune('\n')
+		case "p", "div", "tr", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote":
+			if closing {
+				builder.WriteRune('\n')
+			}
+		case "ul", "ol":
+			switch {
+			case closing && len(markers) > 0:
+				markers = markers[:len(markers)-1]
+			case closing:
+			case tag == "ul":
+				markers = append(markers, "*")
+			default:
+				markers = append(markers, "#")
+			}
+			builder.WriteRune('\n')
+		case "li":
+			if !closing {
+				builder.WriteString("\n" + strings.Join(markers, "") + " ")
+			}
+		case "pre":
+			if closing {
+				builder.WriteString("\n{code}\n")
+			} else {
+				builder.WriteString("{code}\n")
+			}
+		}
+	}
+	builder.WriteString(html.UnescapeString(s[last:]))
+	return strings.TrimSpace(builder.String())
+}
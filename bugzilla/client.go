@@ -0,0 +1,402 @@
+package bugzilla
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nclandrei/ticketguru/jira"
+)
+
+// keyPrefix is prepended to Bugzilla bug IDs so they never collide with Jira keys inside the same bucket.
+const keyPrefix = "BZ-"
+
+// apiKeyHeader is the header Bugzilla reads API keys from.
+const apiKeyHeader = "X-BUGZILLA-API-KEY"
+
+// Client defines the client for the Bugzilla 5 REST API.
+type Client struct {
+	*http.Client
+	URL    *url.URL
+	apiKey string
+	// APIKeyEnv names the environment variable the API key is read from.
+	APIKeyEnv string
+}
+
+// Bug defines a Bugzilla bug as returned by the /rest/bug endpoint.
+type Bug struct {
+	ID             int       `json:"id"`
+	Summary        string    `json:"summary"`
+	Status         string    `json:"status"`
+	Resolution     string    `json:"resolution"`
+	Priority       string    `json:"priority"`
+	Severity       string    `json:"severity"`
+	Type           string    `json:"type"`
+	Product        string    `json:"product"`
+	Component      string    `json:"component"`
+	Creator        string    `json:"creator"`
+	CreationTime   time.Time `json:"creation_time"`
+	Deadline       string    `json:"deadline"`
+	EstimatedTime  float64   `json:"estimated_time"`
+	ActualTime     float64   `json:"actual_time"`
+	RemainingTime  float64   `json:"remaining_time"`
+	LastChangeTime time.Time `json:"last_change_time"`
+}
+
+// BugsResponse defines the response payload retrieved through the bug search endpoint.
+type BugsResponse struct {
+	Bugs     []Bug `json:"bugs"`
+	BugCount int   `json:"bug_count"`
+}
+
+// Comment defines a Bugzilla comment; the comment with count 0 is the bug description.
+type Comment struct {
+	ID           int       `json:"id"`
+	Count        int       `json:"count"`
+	Text         string    `json:"text"`
+	Creator      string    `json:"creator"`
+	CreationTime time.Time `json:"creation_time"`
+	Time         time.Time `json:"time"`
+}
+
+// CommentsResponse defines the response payload retrieved through the comment endpoint.
+type CommentsResponse struct {
+	Bugs map[string]struct {
+		Comments []Comment `json:"comments"`
+	} `json:"bugs"`
+}
+
+// History defines a single change set applied to a bug.
+type History struct {
+	When    time.Time `json:"when"`
+	Who     string    `json:"who"`
+	Changes []Change  `json:"changes"`
+}
+
+// Change defines the change of a single field inside a history entry.
+type Change struct {
+	FieldName string `json:"field_name"`
+	Removed   string `json:"removed"`
+	Added     string `json:"added"`
+}
+
+// HistoryResponse defines the response payload retrieved through the history endpoint.
+type HistoryResponse struct {
+	Bugs []struct {
+		ID      int       `json:"id"`
+		History []History `json:"history"`
+	} `json:"bugs"`
+}
+
+// Attachment defines the metadata of a Bugzilla attachment.
+type Attachment struct {
+	ID           int       `json:"id"`
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int       `json:"size"`
+	Creator      string    `json:"creator"`
+	CreationTime time.Time `json:"creation_time"`
+	IsObsolete   int       `json:"is_obsolete"`
+}
+
+// AttachmentsResponse defines the response payload retrieved through the attachment endpoint.
+type AttachmentsResponse struct {
+	Bugs map[string][]Attachment `json:"bugs"`
+}
+
+// NewClient returns a new Bugzilla Client.
+func NewClient(url *url.URL) (*Client, error) {
+	transport := &http.Transport{
+		Dial: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).Dial,
+		TLSHandshakeTimeout: 60 * time.Second,
+	}
+
+	return &Client{
+		Client: &http.Client{
+			Timeout:   time.Minute * 3,
+			Transport: transport,
+		},
+		URL:       url,
+		APIKeyEnv: "BUGZILLA_API_KEY",
+	}, nil
+}
+
+// AuthenticateClient sets the API key used by the client from the environment variable named by APIKeyEnv.
+// The key is sent with every request to the instance, including attachment downloads going through Do.
+// Public trackers can be queried anonymously, so a missing key is not an error.
+func (client *Client) AuthenticateClient(ctx context.Context) error {
+	client.apiKey = os.Getenv(client.APIKeyEnv)
+	base := client.Transport
+	if keyed, ok := base.(*apiKeyTransport); ok {
+		base = keyed.Base
+	}
+	client.Transport = base
+	if client.apiKey != "" {
+		client.Transport = &apiKeyTransport{
+			Base:   base,
+			Host:   client.URL.Host,
+			APIKey: client.apiKey,
+		}
+	}
+	return nil
+}
+
+// apiKeyTransport wraps an http.RoundTripper and adds the API key to every request sent to the host of the
+// Bugzilla instance, so the key never leaks to other hosts.
+type apiKeyTransport struct {
+	Base   http.RoundTripper
+	Host   string
+	APIKey string
+}
+
+// RoundTrip executes a single HTTP transaction, adding the API key to requests sent to the instance.
+func (t *apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.URL.Host == t.Host && req.Header.Get(apiKeyHeader) == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(apiKeyHeader, t.APIKey)
+	}
+	return base.RoundTrip(req)
+}
+
+// endpoint returns the absolute URL for a REST path and query without mutating the client URL.
+func (client *Client) endpoint(path string, query url.Values) string {
+	u := *client.URL
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// get performs a GET request against the Bugzilla REST API and decodes the JSON response into v.
+func (client *Client) get(ctx context.Context, path string, query url.Values, v interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, "GET", client.endpoint(path, query), nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Add("Accept", "application/json")
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code different than 200 for %s: %v", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Tickets returns a paginated slice of bugs for a Bugzilla product, converted into Jira issues.
+func (client *Client) Tickets(
+	ctx context.Context,
+	product string,
+	paginationIndex int,
+	pageCount int) ([]jira.JiraIssue, error) {
+
+	query := make(url.Values)
+	query.Add("product", product)
+	query.Add("order", "bug_id")
+	query.Add("offset", strconv.Itoa(paginationIndex*pageCount))
+	query.Add("limit", strconv.Itoa(pageCount))
+	var bugsResponse BugsResponse
+	if err := client.get(ctx, "/rest/bug", query, &bugsResponse); err != nil {
+		return nil, err
+	}
+
+	issues := make([]jira.JiraIssue, 0, len(bugsResponse.Bugs))
+	for _, bug := range bugsResponse.Bugs {
+		issue, err := client.ticket(ctx, bug)
+		if err != nil {
+			return issues, fmt.Errorf("could not retrieve bug %d: %v", bug.ID, err)
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+// TicketsCount returns the total number of bugs for a Bugzilla product.
+func (client *Client) TicketsCount(ctx context.Context, product string) (int, error) {
+	query := make(url.Values)
+	query.Add("product", product)
+	query.Add("count_only", "1")
+	var bugsResponse BugsResponse
+	if err := client.get(ctx, "/rest/bug", query, &bugsResponse); err != nil {
+		return -1, err
+	}
+	return bugsResponse.BugCount, nil
+}
+
+// ticket fetches the comments, history and attachments of a bug and converts everything into a Jira issue.
+func (client *Client) ticket(ctx context.Context, bug Bug) (jira.JiraIssue, error) {
+	id := strconv.Itoa(bug.ID)
+
+	var commentsResponse CommentsResponse
+	if err := client.get(ctx, "/rest/bug/"+id+"/comment", nil, &commentsResponse); err != nil {
+		return jira.JiraIssue{}, err
+	}
+	var historyResponse HistoryResponse
+	if err := client.get(ctx, "/rest/bug/"+id+"/history", nil, &historyResponse); err != nil {
+		return jira.JiraIssue{}, err
+	}
+	var attachmentsResponse AttachmentsResponse
+	query := make(url.Values)
+	query.Add("exclude_fields", "data")
+	if err := client.get(ctx, "/rest/bug/"+id+"/attachment", query, &attachmentsResponse); err != nil {
+		return jira.JiraIssue{}, err
+	}
+
+	var history []History
+	for _, h := range historyResponse.Bugs {
+		if h.ID == bug.ID {
+			history = h.History
+		}
+	}
+
+	return client.convert(
+		bug,
+		commentsResponse.Bugs[id].Comments,
+		history,
+		attachmentsResponse.Bugs[id],
+	), nil
+}
+
+// convert maps a Bugzilla bug along with its comments, history and attachments into a Jira issue.
+func (client *Client) convert(bug Bug, comments []Comment, history []History, attachments []Attachment) jira.JiraIssue {
+	issue := jira.JiraIssue{
+		Key:  keyPrefix + strconv.Itoa(bug.ID),
+		ID:   strconv.Itoa(bug.ID),
+		Self: client.endpoint("/rest/bug/"+strconv.Itoa(bug.ID), nil),
+		Fields: jira.Fields{
+			Summary:      bug.Summary,
+			TimeEstimate: hoursToSeconds(bug.EstimatedTime),
+			TimeSpent:    hoursToSeconds(bug.ActualTime),
+			Created:      jira.Time(bug.CreationTime),
+			Status:       jira.Status{Name: statusName(bug.Status)},
+			Priority:     priority(bug.Priority),
+			Type:         jira.Type{Name: bugType(bug.Type)},
+		},
+	}
+
+	if bug.Deadline != "" {
+		if deadline, err := time.Parse("2006-01-02", bug.Deadline); err == nil {
+			issue.Fields.DueDate = jira.Time(deadline)
+		}
+	}
+
+	for _, c := range comments {
+		if c.Count == 0 {
+			issue.Fields.Description = c.Text
+			continue
+		}
+		issue.Fields.Comments.Comments = append(issue.Fields.Comments.Comments, jira.Comment{
+			ID:      strconv.Itoa(c.ID),
+			Body:    c.Text,
+			Author:  author(c.Creator),
+			Created: jira.Time(c.CreationTime),
+			Updated: jira.Time(c.Time),
+		})
+	}
+
+	for _, a := range attachments {
+		if a.IsObsolete != 0 {
+			continue
+		}
+		query := make(url.Values)
+		query.Add("id", strconv.Itoa(a.ID))
+		issue.Fields.Attachments = append(issue.Fields.Attachments, jira.Attachment{
+			ID:       strconv.Itoa(a.ID),
+			Author:   author(a.Creator),
+			Filename: a.FileName,
+			Created:  jira.Time(a.CreationTime),
+			Size:     a.Size,
+			MimeType: a.ContentType,
+			Content:  client.endpoint("/attachment.cgi", query),
+		})
+	}
+
+	for i, h := range history {
+		changelogHistory := jira.ChangelogHistory{
+			ID:      strconv.Itoa(i),
+			Author:  author(h.Who),
+			Created: jira.Time(h.When),
+		}
+		for _, c := range h.Changes {
+			item := jira.ChangelogHistoryItem{
+				Field:      c.FieldName,
+				FieldType:  "bugzilla",
+				FromString: c.Removed,
+				ToString:   c.Added,
+			}
+			if c.FieldName == "status" || c.FieldName == "bug_status" {
+				item.Field = "status"
+				item.FromString = statusName(c.Removed)
+				item.ToString = statusName(c.Added)
+			}
+			changelogHistory.Items = append(changelogHistory.Items, item)
+		}
+		issue.Changelog.Histories = append(issue.Changelog.Histories, changelogHistory)
+	}
+	issue.Changelog.MaxResults = len(issue.Changelog.Histories)
+	issue.Changelog.Total = len(issue.Changelog.Histories)
+
+	return issue
+}
+
+// statusName maps Bugzilla statuses onto the Jira status names used throughout the analysis.
+func statusName(status string) string {
+	switch strings.ToUpper(status) {
+	case "UNCONFIRMED", "NEW", "ASSIGNED", "REOPENED", "IN_PROGRESS", "CONFIRMED":
+		return "Open"
+	case "RESOLVED":
+		return "Resolved"
+	case "VERIFIED", "CLOSED":
+		return "Closed"
+	case "":
+		return ""
+	default:
+		return strings.ToUpper(status[:1]) + strings.ToLower(status[1:])
+	}
+}
+
+// priority maps both Mozilla style (P1-P5) and Red Hat style (urgent, high...) priorities onto Jira priority
+// IDs through the same table as label-based trackers, where lower IDs mean higher priority.
+func priority(p string) jira.Priority {
+	id, ok := jira.DefaultPriorityLabels[strings.ToLower(p)]
+	if !ok {
+		return jira.Priority{Name: p}
+	}
+	return jira.Priority{ID: id, Name: p}
+}
+
+// bugType returns the type of a bug, defaulting to "Bug" for Bugzilla versions without the type field.
+func bugType(t string) string {
+	if t == "" || t == "--" {
+		return "Bug"
+	}
+	return t
+}
+
+// author builds a Jira author from a Bugzilla login.
+func author(login string) jira.Author {
+	return jira.Author{
+		Name:  login,
+		Email: login,
+	}
+}
+
+// hoursToSeconds converts Bugzilla's time tracking hours into the seconds used by Jira.
+func hoursToSeconds(h float64) int {
+	return int(h * 3600)
+}
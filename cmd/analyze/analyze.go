@@ -7,9 +7,14 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/nclandrei/ticketguru/analyze"
 	"github.com/nclandrei/ticketguru/db"
+	"github.com/nclandrei/ticketguru/jira"
 	"log"
 	"os"
+	"os/signal"
+	"sort"
+	"strings"
 	"sync"
+	"syscall"
 )
 
 func main() {
@@ -19,61 +24,69 @@ func main() {
 	}
 
 	var analysisType string
-	flag.StringVar(&analysisType, "type", "all", "type of analysis to run; available types: grammar, sentiment, "+
-		"stack_traces, steps_to_reproduce, attachments, comment_complexity, fields_complexity, all")
+	flag.StringVar(&analysisType, "type", "all", "comma-separated types of analysis to run; available types: "+
+		"grammar and sentiment (scored by the paid Bing and GCP APIs), stack_traces, steps_to_reproduce, "+
+		"attachments, comment_complexity, fields_complexity, blocked_duration, effort, sprints and all (every "+
+		"analysis but grammar and sentiment, e.g. all,grammar,sentiment to run every one)")
+	var onlyStale bool
+	flag.BoolVar(&onlyStale, "stale", false, "only analyze tickets fetched or updated since the selected "+
+		"analyses last ran on them")
 
 	flag.Parse()
 
+	types, err := analysisTypes(analysisType)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	err = godotenv.Load()
 	if err != nil {
 		log.Fatalf("could not load .env file: %v\n", err)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interruptCh := make(chan os.Signal, 2)
+	signal.Notify(interruptCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interruptCh
+		log.Printf("interrupt issued... saving the scores retrieved so far; interrupt again to exit now...")
+		cancel()
+		<-interruptCh
+		os.Exit(1)
+	}()
+
 	var clients []analyze.Scorer
 	var analysisFuncs []analyze.TicketAnalysis
-	analysisFuncs = append(analysisFuncs, analyze.TimesToClose)
-
-	switch analysisType {
-	case "grammar":
-		clients = append(clients, analyze.NewBingClient(os.Getenv("BING_KEY_1")))
-		break
-	case "sentiment":
-		sentimentClient, err := analyze.NewSentimentClient(context.Background())
-		if err != nil {
-			log.Fatalf("could not create GCP sentiment client: %v\n", err)
+	for _, t := range types {
+		switch t {
+		case "grammar":
+			clients = append(clients, analyze.NewBingClient(os.Getenv("BING_KEY_1")))
+		case "sentiment":
+			sentimentClient, err := analyze.NewSentimentClient(ctx)
+			if err != nil {
+				log.Fatalf("could not create GCP sentiment client: %v\n", err)
+			}
+			clients = append(clients, sentimentClient)
+		default:
+			analysisFuncs = append(analysisFuncs, localAnalyses[t])
 		}
-		clients = append(clients, sentimentClient)
-		break
-	case "steps_to_reproduce":
-		analysisFuncs = append(analysisFuncs, analyze.StepsToReproduce)
-		break
-	case "stack_traces":
-		analysisFuncs = append(analysisFuncs, analyze.StackTraces)
-		break
-	case "attachments":
-		analysisFuncs = append(analysisFuncs, analyze.Attachments)
-		break
-	case "comment_complexity":
-		analysisFuncs = append(analysisFuncs, analyze.CommentsComplexity)
-		break
-	case "fields_complexity":
-		analysisFuncs = append(analysisFuncs, analyze.FieldsComplexity)
-		break
-	case "all":
-		analysisFuncs = append(analysisFuncs, analyze.StepsToReproduce, analyze.StackTraces, analyze.Attachments,
-			analyze.CommentsComplexity, analyze.FieldsComplexity)
-		break
-	default:
-		fmt.Printf("%s is not a valid analysis type; available types are grammar, sentiment and all", analysisType)
-		os.Exit(1)
 	}
 
-	tickets, err := boltDB.Tickets()
+	var tickets []jira.JiraIssue
+	if onlyStale {
+		tickets, err = staleTickets(boltDB, types)
+	} else {
+		tickets, err = boltDB.Tickets()
+	}
 	if err != nil {
 		log.Fatalf("could not get all issues inside the database: %v\n", err)
 	}
 
-	analyze.MultipleScores(tickets, clients...)
+	if err := analyze.MultipleScores(ctx, tickets, clients...); err != nil {
+		log.Printf("error while scoring tickets: %v\n", err)
+	}
 
 	var wg sync.WaitGroup
 	for _, f := range analysisFuncs {
@@ -86,8 +99,108 @@ func main() {
 
 	wg.Wait()
 
-	err = boltDB.Insert(tickets...)
+	// Every selected analysis has now been attempted on the tickets, including those it could not score, so
+	// -stale does not select them again until they are next marked stale. Scores interrupted before being
+	// retrieved are left to the next run.
+	all := append(allTypes(), "time_to_close")
+	for i := range tickets {
+		for _, t := range types {
+			if ctx.Err() != nil && !scored(tickets[i], t) {
+				continue
+			}
+			tickets[i].MarkAnalyzed(t, all)
+		}
+	}
+
+	// Scores are costly to retrieve, so those retrieved before an interrupt are saved regardless.
+	err = boltDB.Insert(context.Background(), tickets...)
 	if err != nil {
 		log.Fatalf("could not insert tickets: %v\n", err)
 	}
 }
+
+// localAnalyses maps the analysis types computed without external services onto their analysis.
+var localAnalyses = map[string]analyze.TicketAnalysis{
+	"time_to_close":      analyze.TimesToClose,
+	"steps_to_reproduce": analyze.StepsToReproduce,
+	"stack_traces":       analyze.StackTraces,
+	"attachments":        analyze.Attachments,
+	"comment_complexity": analyze.CommentsComplexity,
+	"fields_complexity":  analyze.FieldsComplexity,
+	"blocked_duration":   analyze.BlockedDurations,
+	"effort":             analyze.Efforts,
+	"sprints":            analyze.SprintMetrics,
+}
+
+// scorerTypes lists the analysis types scored by paid APIs, which only run when asked for by name.
+var scorerTypes = []string{"grammar", "sentiment"}
+
+// analysisTypes returns the analysis types selected by the -type flag, all standing for every local analysis.
+// Times to close are computed by every run, as other analyses depend on them.
+func analysisTypes(flagValue string) ([]string, error) {
+	selected := map[string]bool{"time_to_close": true}
+	for _, t := range strings.Split(flagValue, ",") {
+		t = strings.TrimSpace(t)
+		switch {
+		case t == "all":
+			for local := range localAnalyses {
+				selected[local] = true
+			}
+		case localAnalyses[t] != nil || t == "grammar" || t == "sentiment":
+			selected[t] = true
+		default:
+			return nil, fmt.Errorf("%s is not a valid analysis type; available types are %s and all", t,
+				strings.Join(allTypes(), ", "))
+		}
+	}
+	var types []string
+	for t := range selected {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types, nil
+}
+
+// allTypes returns every analysis type that can be selected by name, sorted.
+func allTypes() []string {
+	types := append([]string(nil), scorerTypes...)
+	for t := range localAnalyses {
+		if t != "time_to_close" {
+			types = append(types, t)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// staleTickets returns the stale tickets on which some of the given analyses have not been attempted since
+// they were last marked stale.
+func staleTickets(boltDB *db.Bolt, types []string) ([]jira.JiraIssue, error) {
+	stale, err := boltDB.StaleTickets()
+	if err != nil {
+		return nil, err
+	}
+	var pending []jira.JiraIssue
+	for _, ticket := range stale {
+		for _, t := range types {
+			if !ticket.Analyzed(t) {
+				pending = append(pending, ticket)
+				break
+			}
+		}
+	}
+	return pending, nil
+}
+
+// scored returns whether a ticket holds the result of an analysis: always for local analyses, and once its
+// score has been retrieved for the scored ones.
+func scored(ticket jira.JiraIssue, analysisType string) bool {
+	switch analysisType {
+	case "grammar":
+		return ticket.GrammarCorrectness.HasScore
+	case "sentiment":
+		return ticket.Sentiment.HasScore
+	default:
+		return true
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/nclandrei/ticketguru/db"
+	"github.com/nclandrei/ticketguru/jira"
+)
+
+var (
+	dbPath  = flag.String("dbPath", "issues.db", "absolute path to the Bolt database")
+	outPath = flag.String("out", "", "path of the CSV file to write; standard output if empty")
+)
+
+// header lists the columns of the exported CSV.
+var header = []string{
+	"key", "instance", "project", "type", "status", "priority", "created", "time_to_close_h", "sentiment", "grammar_errors",
+	"has_stack_trace", "has_steps_to_reproduce", "summary_description_words", "comment_words",
+}
+
+func main() {
+	flag.Parse()
+
+	boltDB, err := db.NewBolt(*dbPath)
+	if err != nil {
+		log.Fatalf("could not access Bolt DB: %v\n", err)
+	}
+
+	tickets, err := boltDB.Tickets()
+	if err != nil {
+		log.Fatalf("could not get all issues inside the database: %v\n", err)
+	}
+
+	if err := exportFile(*outPath, tickets...); err != nil {
+		log.Fatalf("could not export tickets: %v\n", err)
+	}
+}
+
+// exportFile writes a variadic number of tickets as CSV into the file at path, or to the standard output if
+// path is empty, returning any error raised while writing, flushing or closing the file.
+func exportFile(path string, tickets ...jira.JiraIssue) error {
+	if path == "" {
+		return export(os.Stdout, tickets...)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create CSV file: %v", err)
+	}
+	if err := export(file, tickets...); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// export writes a variadic number of tickets with their derived metrics as CSV. Scores that have not been
+// computed, and the time-to-close of tickets not closed or not analyzed yet, are left empty rather than written
+// as zero.
+func export(out io.Writer, tickets ...jira.JiraIssue) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, t := range tickets {
+		var timeToClose, sentiment, grammar string
+		if t.TimeToClose > 0 {
+			timeToClose = strconv.FormatFloat(t.TimeToClose, 'f', -1, 64)
+		}
+		if t.Sentiment.HasScore {
+			sentiment = strconv.FormatFloat(t.Sentiment.Score, 'f', -1, 64)
+		}
+		if t.GrammarCorrectness.HasScore {
+			grammar = strconv.Itoa(t.GrammarCorrectness.Score)
+		}
+		record := []string{
+			t.Key,
+			t.Instance,
+			t.Project,
+			t.Fields.Type.Name,
+			t.Fields.Status.Name,
+			t.Fields.Priority.Name,
+			time.Time(t.Fields.Created).Format(time.RFC3339),
+			timeToClose,
+			sentiment,
+			grammar,
+			strconv.FormatBool(t.HasStackTrace),
+			strconv.FormatBool(t.HasStepsToReproduce),
+			strconv.Itoa(t.SummaryDescWordsCount),
+			strconv.Itoa(t.CommentWordsCount),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/nclandrei/ticketguru/backup"
+	"github.com/nclandrei/ticketguru/db"
+	"github.com/nclandrei/ticketguru/jira"
+)
+
+var (
+	format    = flag.String("format", "auto", "format of the exports; available formats: xml, json, auto (by file extension)")
+	fieldMap  = flag.String("fieldMapping", "", "path to a JSON file mapping logical names onto extra Jira field IDs")
+	batchSize = flag.Int("batchSize", 500, "number of tickets inserted into Bolt per transaction")
+	instance  = flag.String("instance", "jira", "name of the Jira instance the exports are stored under")
+	dbPath    = flag.String("dbPath", "issues.db", "absolute path to the Bolt database")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] export...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	logger := log.New(os.Stdout, "jira-import: ", log.Lshortfile)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interruptCh := make(chan os.Signal, 2)
+	signal.Notify(interruptCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interruptCh
+		logger.Printf("interrupt issued... finishing pending writes; interrupt again to exit now...")
+		cancel()
+		<-interruptCh
+		os.Exit(1)
+	}()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *batchSize < 1 {
+		logger.Fatalf("batch size must be at least 1, got %d\n", *batchSize)
+	}
+
+	var mapping map[string]string
+	if *fieldMap != "" {
+		var err error
+		mapping, err = jira.LoadFieldMapping(*fieldMap)
+		if err != nil {
+			logger.Fatalf("could not load field mapping: %v\n", err)
+		}
+	}
+
+	boltDB, err := db.NewBolt(*dbPath)
+	if err != nil {
+		logger.Fatalf("could not create Bolt DB: %v\n", err)
+	}
+
+	for _, path := range flag.Args() {
+		count, err := importFile(ctx, boltDB, path, mapping)
+		if err != nil {
+			logger.Fatalf("could not import %s: %v\n", path, err)
+		}
+		logger.Printf("imported %d tickets from %s\n", count, path)
+	}
+}
+
+// importFile streams the tickets of an export into Bolt in batches, tagging them with the instance and the
+// project of their key and marking them as stale, and returns how many tickets were imported.
+func importFile(ctx context.Context, boltDB *db.Bolt, path string, mapping map[string]string) (int, error) {
+	read := backup.ReadJSON
+	switch fileFormat(path) {
+	case "xml":
+		read = backup.ReadXML
+	case "json":
+	default:
+		return 0, fmt.Errorf("unknown export format; use -format xml or -format json")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var count int
+	batch := make([]jira.JiraIssue, 0, *batchSize)
+	err = read(file, mapping, func(issue jira.JiraIssue) error {
+		issue.Instance = *instance
+		issue.Project = jira.ProjectKey(issue.Key)
+		issue.MarkStale()
+		batch = append(batch, issue)
+		if len(batch) < *batchSize {
+			return nil
+		}
+		if err := boltDB.InsertMany(ctx, batch...); err != nil {
+			return err
+		}
+		count += len(batch)
+		batch = batch[:0]
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	if err := boltDB.InsertMany(ctx, batch...); err != nil {
+		return count, err
+	}
+	return count + len(batch), nil
+}
+
+// fileFormat returns the format of an export, as set by -format or guessed from its extension.
+func fileFormat(path string) string {
+	if *format != "auto" {
+		return *format
+	}
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/nclandrei/ticketguru/plot"
 	"log"
 	"os"
+	"strings"
 	"sync"
 )
 
@@ -18,6 +19,8 @@ var (
 	)
 	pType = flag.String("type", "all", "plot(s) to draw - available types: grammar, sentiment, steps_to_reprodce"+
 		"stack_traces, attachments, comments_complexity, fields_complexity, all")
+	customFields = flag.String("customFields", "", "comma separated logical names of numeric mapped fields to "+
+		"plot against time-to-close")
 )
 
 func main() {
@@ -56,6 +59,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	for _, name := range strings.Split(*customFields, ",") {
+		if name != "" {
+			funcs = append(funcs, plot.CustomField(name))
+		}
+	}
+
 	boltDB, err := db.NewBolt(*dbPath)
 	if err != nil {
 		log.Fatalf("could not open bolt db: %v\n", err)
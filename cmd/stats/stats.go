@@ -2,9 +2,14 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"github.com/nclandrei/ticketguru/db"
+	"github.com/nclandrei/ticketguru/graph"
+	"github.com/nclandrei/ticketguru/jira"
 	"github.com/nclandrei/ticketguru/stats"
 	"log"
+	"sort"
+	"strings"
 	"sync"
 )
 
@@ -14,6 +19,12 @@ var (
 		"/Users/nclandrei/Code/go/src/github.com/nclandrei/ticketguru/issues.db",
 		"path to Bolt database file",
 	)
+	customFields = flag.String("customFields", "", "comma separated logical names of numeric mapped fields to "+
+		"correlate with time-to-close")
+	linkComponents = flag.String("linkComponents", "", "comma separated link types (e.g. Duplicate,Blocks) "+
+		"whose connected components should be reported")
+	stratify = flag.String("stratify", "", "run the tests separately for the tickets of each instance or project "+
+		"they were crawled from; available strata: instance, project")
 )
 
 func main() {
@@ -32,12 +43,26 @@ func main() {
 		"Attachments":        stats.Attachments,
 		"Steps To Reproduce": stats.StepsToReproduce,
 		"Stack Traces":       stats.Stacktraces,
+		"Duplicated":         stats.Links("Duplicate"),
+		"Blocked":            stats.Links("Blocks"),
+		"Cloned":             stats.Links("Cloners"),
 	}
 	continuousTests := map[string]stats.ContinuousTest{
 		"Comments Complexity": stats.CommentsComplexity,
 		"Fields Complexity":   stats.FieldsComplexity,
 		"Sentiment Analysis":  stats.Sentiment,
 		"Grammar Correctness": stats.Grammar,
+		"Links Count":         stats.LinksCount,
+		"Blocked Duration":    stats.BlockedDuration,
+		"Effort Contributors": stats.EffortContributors,
+		"Effort Sessions":     stats.EffortSessions,
+		"Effort Ratio":        stats.EffortRatio,
+		"Sprint Carry-overs":  stats.SprintCarryOvers,
+	}
+	for _, name := range strings.Split(*customFields, ",") {
+		if name != "" {
+			continuousTests[name] = stats.CustomField(name)
+		}
 	}
 
 	tickets, err := boltDB.Tickets()
@@ -45,6 +70,41 @@ func main() {
 		log.Fatalf("could not fetch tickets from bolt db: %v\n", err)
 	}
 
+	if *linkComponents != "" {
+		components := graph.New(tickets...).ConnectedComponents(strings.Split(*linkComponents, ",")...)
+		var linked int
+		for _, c := range components {
+			linked += len(c)
+		}
+		log.Printf("Link Components (%s) --- components: %d --- tickets: %d\n", *linkComponents, len(components), linked)
+		if len(components) > 0 {
+			log.Printf("Largest component: %s\n", strings.Join(components[0], ", "))
+		}
+	}
+
+	strata, err := stratifyTickets(*stratify, tickets...)
+	if err != nil {
+		log.Fatalf("could not stratify tickets: %v\n", err)
+	}
+	var names []string
+	for name := range strata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		runTests(name, categoricalTests, continuousTests, strata[name]...)
+	}
+}
+
+// runTests runs the categorical and continuous tests on a variadic number of tickets, prefixing the results
+// with the name of their stratum, if any.
+func runTests(stratum string, categoricalTests map[string]stats.CategoricalTest,
+	continuousTests map[string]stats.ContinuousTest, tickets ...jira.JiraIssue) {
+	prefix := ""
+	if stratum != "" {
+		prefix = "[" + stratum + "] "
+	}
+
 	var wg sync.WaitGroup
 	for k, v := range categoricalTests {
 		wg.Add(1)
@@ -52,10 +112,11 @@ func main() {
 			defer wg.Done()
 			result, err := f(tickets...)
 			if err != nil {
-				log.Printf("could not compute statistical test: %v\n", err)
+				log.Printf("%scould not compute statistical test: %v\n", prefix, err)
 				return
 			}
-			log.Printf("%s --- P: %f --- mean_1: %f --- mean_2: %f\n", name, result.P, result.N1Mean, result.N2Mean)
+			log.Printf("%s%s --- P: %f --- mean_1: %f --- mean_2: %f\n", prefix, name, result.P, result.N1Mean,
+				result.N2Mean)
 		}(k, v)
 	}
 
@@ -64,12 +125,29 @@ func main() {
 		go func(name string, f stats.ContinuousTest) {
 			defer wg.Done()
 			result := f(tickets...)
-			if err != nil {
-				log.Printf("could not compute statistical test: %v\n", err)
-			}
-			log.Printf("%s --- Rs: %f --- P: %f\n", name, result.Rs, result.P)
+			log.Printf("%s%s --- Rs: %f --- P: %f\n", prefix, name, result.Rs, result.P)
 		}(k, v)
 	}
 
 	wg.Wait()
 }
+
+// stratifyTickets groups a variadic number of tickets by the instance or project they were crawled from;
+// without a stratum, every ticket is placed in a single unnamed group.
+func stratifyTickets(stratum string, tickets ...jira.JiraIssue) (map[string][]jira.JiraIssue, error) {
+	strata := make(map[string][]jira.JiraIssue)
+	for _, t := range tickets {
+		var name string
+		switch stratum {
+		case "":
+		case "instance":
+			name = t.Instance
+		case "project":
+			name = t.Instance + "/" + t.Project
+		default:
+			return nil, fmt.Errorf("%s is not a valid stratum; available strata are instance and project", stratum)
+		}
+		strata[name] = append(strata[name], t)
+	}
+	return strata, nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Manifest defines the tracker instances, and the projects of each, crawled by a single run of the store command.
+type Manifest struct {
+	Instances []Instance `json:"instances"`
+}
+
+// Instance defines a tracker instance to crawl and the projects to fetch from it. Credentials maps the
+// environment variables a source reads its credentials from by default (e.g. JIRA_USERNAME) onto the ones
+// holding the credentials of this instance, so that several instances can be configured in the same .env file.
+// Concurrency pages of PageSize tickets are fetched at a time, while RequestsPerSecond caps the rate of requests
+// sent to the instance by all of them.
+type Instance struct {
+	Name              string            `json:"name"`
+	Source            string            `json:"source"`
+	URL               string            `json:"url"`
+	ContextPath       string            `json:"contextPath,omitempty"`
+	APIVersion        string            `json:"apiVersion,omitempty"`
+	Auth              string            `json:"auth,omitempty"`
+	Credentials       map[string]string `json:"credentials,omitempty"`
+	FieldMapping      string            `json:"fieldMapping,omitempty"`
+	Concurrency       int               `json:"concurrency,omitempty"`
+	PageSize          int               `json:"pageSize,omitempty"`
+	RequestsPerSecond float64           `json:"requestsPerSecond,omitempty"`
+	Projects          []Project         `json:"projects"`
+}
+
+// Project defines a project to crawl; for Jira instances, JQL overrides the query selecting all issues of the
+// project, and Name may then be left empty. Jira issues are always tagged with the project of their own key,
+// so a JQL expression spanning several projects stores each issue with its project.
+type Project struct {
+	Name string `json:"name"`
+	JQL  string `json:"jql,omitempty"`
+}
+
+// label returns the name of the project, or its JQL expression if it has no name.
+func (p Project) label() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.JQL
+}
+
+// loadManifest reads a manifest from a JSON file, filling in the defaults of the instances.
+func loadManifest(path string) (*Manifest, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("could not decode manifest: %v", err)
+	}
+	for i := range manifest.Instances {
+		instance := &manifest.Instances[i]
+		if instance.Source == "" {
+			instance.Source = "jira"
+		}
+		if instance.ContextPath == "" {
+			instance.ContextPath = "auto"
+		}
+		if instance.APIVersion == "" {
+			instance.APIVersion = "2"
+		}
+		if instance.Auth == "" {
+			instance.Auth = "session"
+		}
+		if instance.Concurrency == 0 {
+			instance.Concurrency = *gortnCnt
+		}
+		if instance.PageSize == 0 {
+			instance.PageSize = *pageSize
+		}
+		if instance.RequestsPerSecond == 0 {
+			instance.RequestsPerSecond = *rps
+		}
+	}
+	return &manifest, nil
+}
+
+// flagManifest returns the manifest of a single instance and project described by the command line flags.
+// The instance is named after its source, so runs keep the sync times and checkpoints of earlier versions.
+// -jql overrides -project, so the default project is dropped unless -project is given explicitly.
+func flagManifest() *Manifest {
+	name := *project
+	if *jql != "" && !flagSet("project") {
+		name = ""
+	}
+	instance := Instance{
+		Name:              *source,
+		Source:            *source,
+		ContextPath:       *jiraContext,
+		APIVersion:        *jiraVersion,
+		Auth:              *auth,
+		FieldMapping:      *fieldMap,
+		Concurrency:       *gortnCnt,
+		PageSize:          *pageSize,
+		RequestsPerSecond: *rps,
+		Projects:          []Project{{Name: name, JQL: *jql}},
+	}
+	switch *source {
+	case "jira":
+		instance.URL = *jiraURL
+	case "bugzilla":
+		instance.URL = *bugzillaURL
+	case "github":
+		instance.URL = *githubURL
+	case "gitlab":
+		instance.URL = *gitlabURL
+	}
+	return &Manifest{Instances: []Instance{instance}}
+}
+
+// validate checks that every instance of the manifest can be crawled.
+func (m *Manifest) validate() error {
+	if len(m.Instances) == 0 {
+		return fmt.Errorf("manifest lists no instances")
+	}
+	names := make(map[string]bool)
+	for _, instance := range m.Instances {
+		if instance.Name == "" {
+			return fmt.Errorf("every instance must have a name")
+		}
+		if names[instance.Name] {
+			return fmt.Errorf("instance %s is listed more than once", instance.Name)
+		}
+		names[instance.Name] = true
+		switch instance.Source {
+		case "jira", "bugzilla", "github", "gitlab":
+		default:
+			return fmt.Errorf("%s is not a valid source for instance %s; available sources are jira, bugzilla, "+
+				"github and gitlab", instance.Source, instance.Name)
+		}
+		if instance.Concurrency < 1 {
+			return fmt.Errorf("concurrency of instance %s must be at least 1", instance.Name)
+		}
+		if instance.PageSize < 1 {
+			return fmt.Errorf("page size of instance %s must be at least 1", instance.Name)
+		}
+		if instance.RequestsPerSecond <= 0 {
+			return fmt.Errorf("requests per second of instance %s must be positive", instance.Name)
+		}
+		if len(instance.Projects) == 0 {
+			return fmt.Errorf("instance %s lists no projects", instance.Name)
+		}
+		for _, project := range instance.Projects {
+			if project.Name == "" && project.JQL == "" {
+				return fmt.Errorf("every project of instance %s must have a name or a jql expression", instance.Name)
+			}
+			if project.JQL != "" && instance.Source != "jira" {
+				return fmt.Errorf("jql can only be used with jira instances, not with %s", instance.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// flagSet returns whether a command line flag has been set explicitly.
+func flagSet(name string) bool {
+	var set bool
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// env returns the value of the environment variable holding the given credential of the instance.
+func (i Instance) env(name string) string {
+	return os.Getenv(i.envName(name))
+}
+
+// envName returns the name of the environment variable holding the given credential of the instance.
+func (i Instance) envName(name string) string {
+	if mapped, ok := i.Credentials[name]; ok {
+		return mapped
+	}
+	return name
+}
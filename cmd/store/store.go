@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"github.com/joho/godotenv"
+	"io/ioutil"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/nclandrei/ticketguru/db"
 
@@ -14,17 +19,59 @@ import (
 	"math"
 	"net/url"
 
+	"github.com/nclandrei/ticketguru/attachment"
+	"github.com/nclandrei/ticketguru/backup"
+	"github.com/nclandrei/ticketguru/bugzilla"
+	"github.com/nclandrei/ticketguru/github"
+	"github.com/nclandrei/ticketguru/gitlab"
 	"github.com/nclandrei/ticketguru/jira"
 )
 
-// This defines the maximum number of concurrent client calls to Jira REST API
-// as, otherwise, it would start dropping the connections
-const maxNoGoroutines = 100
+// ticketSource defines a tracker client able to fetch paginated tickets for a project.
+type ticketSource interface {
+	AuthenticateClient(context.Context) error
+	TicketsCount(context.Context, string) (int, error)
+	Tickets(context.Context, string, int, int) ([]jira.JiraIssue, error)
+}
+
+// keyLister defines a tracker client able to cheaply list the keys of every ticket matching a query.
+type keyLister interface {
+	TicketKeys(context.Context, string) ([]string, error)
+}
 
 var (
-	jiraURL     = flag.String("jiraURL", "http://issues.apache.org", "URL for Jira instance")
-	project     = flag.String("project", "Kafka", "name of the project to be queried upon")
-	gortnCnt    = flag.Int("goroutinesCount", maxNoGoroutines, "number of goroutines to be used")
+	source      = flag.String("source", "jira", "tracker to fetch tickets from; available sources: jira, bugzilla, github, gitlab")
+	jiraURL     = flag.String("jiraURL", "https://issues.apache.org/jira", "URL for Jira instance, including its context path")
+	jiraContext = flag.String("jiraContextPath", "auto", "path Jira is mounted at (e.g. /jira, or empty for root); "+
+		"auto uses the path of -jiraURL or, if it has none, probes the root and /jira")
+	jiraVersion = flag.String("jiraAPIVersion", "2", "version of the Jira REST API to query (2 or 3)")
+	bugzillaURL = flag.String("bugzillaURL", "https://bugzilla.mozilla.org", "URL for Bugzilla instance")
+	githubURL   = flag.String("githubURL", "https://api.github.com", "URL for GitHub API")
+	gitlabURL   = flag.String("gitlabURL", "https://gitlab.com", "URL for GitLab instance")
+	project     = flag.String("project", "Kafka", "name of the project (Bugzilla product, GitHub owner/repo, "+
+		"GitLab project path) to be queried upon")
+	jql         = flag.String("jql", "", "JQL expression selecting the Jira issues to fetch; overrides -project")
+	incremental = flag.Bool("incremental", false, "only fetch Jira issues updated since the last successful sync")
+	auth        = flag.String("auth", "session", "Jira authentication method; available methods: session "+
+		"(JIRA_USERNAME, JIRA_PASSWORD), basic (JIRA_EMAIL, JIRA_API_TOKEN), bearer (JIRA_PAT), "+
+		"oauth (JIRA_OAUTH_CONSUMER_KEY, JIRA_OAUTH_PRIVATE_KEY_PATH, JIRA_OAUTH_ACCESS_TOKEN)")
+	fieldMap    = flag.String("fieldMapping", "", "path to a JSON file mapping logical names onto extra Jira field IDs")
+	maxAttempts = flag.Int("maxAttempts", jira.DefaultMaxAttempts, "maximum number of attempts for each Jira request")
+	download    = flag.Bool("downloadAttachments", false, "download attachment bodies into the attachment store")
+	attachDir   = flag.String("attachmentsDir", "attachments", "directory of the content-addressed attachment store")
+	attachMax   = flag.Int64("maxAttachmentSize", 50<<20, "maximum size in bytes of a downloaded attachment (0 for no limit)")
+	attachJobs  = flag.Int("attachmentWorkers", 8, "number of concurrent attachment downloads")
+	csvPath     = flag.String("csv", "", "path to a CSV export of the Jira issue navigator to import instead of fetching")
+	csvInstance = flag.String("csvInstance", "jira", "name of the Jira instance the -csv export is stored under")
+	sprints     = flag.Bool("sprints", false, "record the sprints of the stored issues of -project from its "+
+		"Jira Software boards")
+	manifestPath = flag.String("manifest", "", "path to a JSON manifest listing the instances and projects to crawl; "+
+		"overrides the source, URL, project and Jira client flags")
+	resume   = flag.Bool("resume", false, "resume the last interrupted run, fetching only the pages still missing")
+	gortnCnt = flag.Int("goroutinesCount", 10, "number of pages fetched concurrently per instance")
+	pageSize = flag.Int("pageSize", 50, "number of tickets fetched per page")
+	rps      = flag.Float64("requestsPerSecond", jira.DefaultRequestsPerSecond, "maximum number of requests "+
+		"per second sent to each instance; lowered automatically while the instance throttles or slows down")
 	dbPath      = flag.String("dbPath", "issues.db", "absolute path to the Bolt database")
 	logToFile   = flag.Bool("file_log", false, "specifies whether application should log to file or not")
 	logFilePath = flag.String("log_path", "~/Code/go/src/github.com/nclandrei/ticketguru/log.txt", "path to logging file")
@@ -48,12 +95,16 @@ func main() {
 		}
 	}
 
-	interruptCh := make(chan os.Signal, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interruptCh := make(chan os.Signal, 2)
 	signal.Notify(interruptCh, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-interruptCh
 		log.SetOutput(os.Stderr)
-		log.Printf("interrupt issued... exitting now...")
+		log.Printf("interrupt issued... finishing pending writes; interrupt again to exit now...")
+		cancel()
+		<-interruptCh
 		os.Exit(1)
 	}()
 
@@ -62,53 +113,499 @@ func main() {
 		logger.Fatalf("could not load .env file: %v\n", err)
 	}
 
-	if *gortnCnt > maxNoGoroutines {
-		logger.Fatalf("cannot have more than maximum number of goroutines... exiting now\n")
+	boltDB, err := db.NewBolt(*dbPath)
+	if err != nil {
+		logger.Fatalf("could not create Bolt DB: %v\n", err)
 	}
 
-	clientURL, err := url.Parse(*jiraURL)
-	if err != nil {
-		logger.Fatalf("jira URL provided is not a valid URL: %v\n", err)
+	if *csvPath != "" {
+		if err := importCSV(ctx, logger, boltDB, *csvPath); err != nil {
+			logger.Fatalf("could not import CSV export: %v\n", err)
+		}
+		return
 	}
 
-	jiraClient, err := jira.NewClient(clientURL)
+	manifest := flagManifest()
+	if *manifestPath != "" {
+		manifest, err = loadManifest(*manifestPath)
+		if err != nil {
+			logger.Fatalf("could not load manifest: %v\n", err)
+		}
+	}
+	if err := manifest.validate(); err != nil {
+		logger.Fatalf("invalid manifest: %v\n", err)
+	}
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var failed []string
+
+	for _, instance := range manifest.Instances {
+		wg.Add(1)
+		go func(instance Instance) {
+			defer wg.Done()
+			instanceLogger := log.New(logger.Writer(), logger.Prefix()+instance.Name+": ", logger.Flags())
+			if err := crawlInstance(ctx, instanceLogger, boltDB, instance); err != nil {
+				instanceLogger.Printf("%v\n", err)
+				lock.Lock()
+				failed = append(failed, instance.Name)
+				lock.Unlock()
+			}
+		}(instance)
+	}
+
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		boltDB.Close()
+		logger.Fatalf("run interrupted; rerun with -resume to fetch the missing pages\n")
+	}
+	if len(failed) > 0 {
+		logger.Fatalf("could not crawl %s; rerun with -resume to fetch the missing pages\n", strings.Join(failed, ", "))
+	}
+}
+
+// crawlInstance fetches every project of an instance, then records their sprints and downloads the attachments
+// of the instance's tickets if requested. A project that cannot be crawled does not stop the others.
+func crawlInstance(ctx context.Context, logger *log.Logger, boltDB *db.Bolt, instance Instance) error {
+	client, err := newSource(ctx, instance)
 	if err != nil {
-		logger.Fatalf("could not create Jira client: %v\n", err)
+		return err
 	}
 
-	boltDB, err := db.NewBolt(*dbPath)
+	err = client.AuthenticateClient(ctx)
 	if err != nil {
-		logger.Fatalf("could not create Bolt DB: %v\n", err)
+		return fmt.Errorf("could not authenticate %s client: %v", instance.Source, err)
+	}
+
+	var failed []string
+	for _, project := range instance.Projects {
+		if err := crawlProject(ctx, logger, boltDB, client, instance, project); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Printf("could not crawl project %s: %v\n", project.label(), err)
+			failed = append(failed, project.label())
+		}
+	}
+
+	if *sprints {
+		jiraClient, ok := client.(*jira.Client)
+		if !ok {
+			return fmt.Errorf("-sprints can only be used with jira instances")
+		}
+		for _, project := range instance.Projects {
+			if project.Name == "" {
+				logger.Printf("skipping sprints of %s, which names no project\n", project.label())
+				continue
+			}
+			if err := importSprints(ctx, logger, jiraClient, boltDB, instance.Name, project.Name); err != nil {
+				return fmt.Errorf("could not import sprints of %s: %v", project.Name, err)
+			}
+		}
+	}
+
+	if *download {
+		doer, ok := client.(attachment.Doer)
+		if !ok {
+			return fmt.Errorf("%s client cannot download attachments", instance.Source)
+		}
+		store, err := attachment.NewStore(*attachDir, *attachMax, *attachJobs, doer)
+		if err != nil {
+			return fmt.Errorf("could not create attachment store: %v", err)
+		}
+		tickets, err := boltDB.Tickets()
+		if err != nil {
+			return fmt.Errorf("could not get tickets from bolt: %v", err)
+		}
+		var instanceTickets []jira.JiraIssue
+		for _, ticket := range tickets {
+			if ticket.Instance == instance.Name {
+				instanceTickets = append(instanceTickets, ticket)
+			}
+		}
+		updated, err := store.Download(ctx, instanceTickets...)
+		if err != nil {
+			logger.Printf("error while downloading attachments: %v\n", err)
+		}
+		// The hashes of bodies already in the store are recorded even when interrupted, so they are not
+		// downloaded again.
+		if err := boltDB.Insert(context.Background(), updated...); err != nil {
+			return fmt.Errorf("could not add attachment hashes to bolt: %v", err)
+		}
+		logger.Printf("downloaded attachments of %d tickets\n", len(updated))
 	}
 
-	err = jiraClient.AuthenticateClient()
+	if len(failed) > 0 {
+		return fmt.Errorf("could not crawl projects %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// crawlProject fetches the tickets of a project page by page, tagging them with their instance and project,
+// and records the pages fetched in a checkpoint so that an interrupted crawl can be resumed.
+func crawlProject(ctx context.Context, logger *log.Logger, boltDB *db.Bolt, client ticketSource,
+	instance Instance, project Project) error {
+	query := project.Name
+	if instance.Source == "jira" {
+		query = jira.ProjectQuery(project.Name)
+		if project.JQL != "" {
+			query = project.JQL
+		}
+	}
+
+	syncKey := instance.Name + ":" + query
+	checkpoint, err := boltDB.Checkpoint(syncKey)
 	if err != nil {
-		logger.Fatalf("could not authenticate Jira client: %v\n", err)
+		return fmt.Errorf("could not get checkpoint: %v", err)
+	}
+	if *resume && checkpoint != nil {
+		query = checkpoint.Query
+		logger.Printf("resuming run of %s started at %v: %d/%d pages already fetched\n",
+			project.label(), checkpoint.Started, len(checkpoint.Done), checkpoint.Pages)
+	} else {
+		if *resume {
+			logger.Printf("no checkpoint found for %s; starting a new run\n", syncKey)
+		}
+		checkpoint = nil
+		if *incremental {
+			if instance.Source != "jira" {
+				return fmt.Errorf("-incremental can only be used with jira instances")
+			}
+			lastSync, err := boltDB.LastSync(syncKey)
+			if err != nil {
+				return fmt.Errorf("could not get last sync time: %v", err)
+			}
+			if !lastSync.IsZero() {
+				query = jira.UpdatedSinceQuery(query, lastSync)
+				logger.Printf("fetching issues of %s updated since %v\n", project.label(), lastSync)
+			}
+		}
 	}
 
-	numberOfIssues, err := jiraClient.TicketsCount(*project)
+	numberOfIssues, err := client.TicketsCount(ctx, query)
 	if err != nil {
-		logger.Fatalf("could not get total number of tickets: %v\n", err)
+		return fmt.Errorf("could not get total number of tickets: %v", err)
 	}
 
-	issueSliceSize := math.Ceil(float64(numberOfIssues) / float64(*gortnCnt))
+	if checkpoint == nil {
+		started := time.Now()
+		if numberOfIssues == 0 {
+			logger.Printf("no tickets of %s to fetch\n", project.label())
+			if err := boltDB.SetLastSync(syncKey, started); err != nil {
+				return fmt.Errorf("could not record sync time: %v", err)
+			}
+			return nil
+		}
+		checkpoint = &db.Checkpoint{
+			Query:    query,
+			Started:  started,
+			PageSize: instance.PageSize,
+			Pages:    int(math.Ceil(float64(numberOfIssues) / float64(instance.PageSize))),
+			Done:     make(map[int][]string),
+		}
+		if err := boltDB.SaveCheckpoint(syncKey, *checkpoint); err != nil {
+			return fmt.Errorf("could not save checkpoint: %v", err)
+		}
+	}
 
-	var wg sync.WaitGroup
+	fetch := func(index int) {
+		issues, err := client.Tickets(ctx, query, index, checkpoint.PageSize)
+		if err != nil {
+			logger.Printf("error while getting issues of %s for page %d: %v\n", project.label(), index, err)
+		}
+		for i := range issues {
+			issues[i].MarkStale()
+			issues[i].Instance = instance.Name
+			issues[i].Project = project.Name
+			if instance.Source == "jira" {
+				issues[i].Project = jira.ProjectKey(issues[i].Key)
+			}
+		}
+		insertErr := keepStored(boltDB, issues)
+		if insertErr == nil {
+			insertErr = boltDB.Insert(ctx, issues...)
+		}
+		if insertErr != nil {
+			logger.Printf("could not add issues to bolt: %v\n", insertErr)
+		}
+		if err != nil || insertErr != nil {
+			return
+		}
+		keys := make([]string, len(issues))
+		for i := range issues {
+			keys[i] = issues[i].Key
+		}
+		if err := boltDB.MarkPageDone(syncKey, index, keys); err != nil {
+			logger.Printf("could not checkpoint page %d: %v\n", index, err)
+		}
+	}
 
-	for i := 0; i < *gortnCnt; i++ {
+	pages := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < instance.Concurrency; i++ {
 		wg.Add(1)
-		go func(index int) {
+		go func() {
 			defer wg.Done()
-			issues, err := jiraClient.Tickets(*project, index, int(issueSliceSize))
-			if err != nil {
-				logger.Printf("error while getting issues: %v\n", err)
+			for index := range pages {
+				fetch(index)
 			}
-			err = boltDB.Insert(issues...)
+		}()
+	}
+
+feed:
+	for i := 0; i < checkpoint.Pages; i++ {
+		if _, done := checkpoint.Done[i]; done {
+			continue
+		}
+		select {
+		case pages <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(pages)
+
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	checkpoint, err = boltDB.Checkpoint(syncKey)
+	if err != nil {
+		return fmt.Errorf("could not get checkpoint: %v", err)
+	}
+	if !report(ctx, logger, client, query, numberOfIssues, checkpoint) {
+		return fmt.Errorf("run incomplete")
+	}
+	if err := boltDB.ClearCheckpoint(syncKey); err != nil {
+		return fmt.Errorf("could not clear checkpoint: %v", err)
+	}
+	if err := boltDB.SetLastSync(syncKey, checkpoint.Started); err != nil {
+		return fmt.Errorf("could not record sync time: %v", err)
+	}
+	return nil
+}
+
+// newSource returns the client for the source of an instance.
+func newSource(ctx context.Context, instance Instance) (ticketSource, error) {
+	clientURL, err := url.Parse(instance.URL)
+	if err != nil {
+		return nil, fmt.Errorf("%s URL provided is not a valid URL: %v", instance.Source, err)
+	}
+
+	switch instance.Source {
+	case "jira":
+		authenticator, err := jiraAuthenticator(instance)
+		if err != nil {
+			return nil, fmt.Errorf("could not set up Jira authentication: %v", err)
+		}
+		options := []jira.ClientOption{
+			jira.WithRetries(*maxAttempts),
+			jira.WithAuthenticator(authenticator),
+			jira.WithAPIVersion(instance.APIVersion),
+			jira.WithRateLimit(instance.RequestsPerSecond),
+		}
+		if instance.ContextPath != "auto" {
+			options = append(options, jira.WithContextPath(instance.ContextPath))
+		}
+		if instance.FieldMapping != "" {
+			mapping, err := jira.LoadFieldMapping(instance.FieldMapping)
 			if err != nil {
-				logger.Printf("could not add issues to bolt: %v\n", err)
+				return nil, fmt.Errorf("could not load field mapping: %v", err)
+			}
+			options = append(options, jira.WithCustomFields(mapping))
+		}
+		jiraClient, err := jira.NewClient(clientURL, options...)
+		if err != nil {
+			return nil, fmt.Errorf("could not create Jira client: %v", err)
+		}
+		if instance.ContextPath == "auto" && jiraClient.ContextPath == "" {
+			if err := jiraClient.DiscoverContextPath(ctx); err != nil {
+				return nil, fmt.Errorf("could not discover Jira context path: %v", err)
 			}
-		}(i)
+		}
+		return jiraClient, nil
+	case "bugzilla":
+		client, err := bugzilla.NewClient(clientURL)
+		if err != nil {
+			return nil, fmt.Errorf("could not create Bugzilla client: %v", err)
+		}
+		client.APIKeyEnv = instance.envName(client.APIKeyEnv)
+		client.Transport = jira.NewRateLimiter(client.Transport, instance.RequestsPerSecond)
+		return client, nil
+	case "github":
+		client, err := github.NewClient(clientURL)
+		if err != nil {
+			return nil, fmt.Errorf("could not create GitHub client: %v", err)
+		}
+		client.TokenEnv = instance.envName(client.TokenEnv)
+		client.Transport = jira.NewRateLimiter(client.Transport, instance.RequestsPerSecond)
+		return client, nil
+	case "gitlab":
+		client, err := gitlab.NewClient(clientURL)
+		if err != nil {
+			return nil, fmt.Errorf("could not create GitLab client: %v", err)
+		}
+		client.TokenEnv = instance.envName(client.TokenEnv)
+		client.Transport = jira.NewRateLimiter(client.Transport, instance.RequestsPerSecond)
+		return client, nil
+	default:
+		return nil, fmt.Errorf("%s is not a valid source; available sources are jira, bugzilla, github and gitlab",
+			instance.Source)
 	}
+}
 
-	wg.Wait()
+// importCSV inserts the tickets of a CSV export of the Jira issue navigator into Bolt, marking them as stale.
+func importCSV(ctx context.Context, logger *log.Logger, boltDB *db.Bolt, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var tickets []jira.JiraIssue
+	err = backup.ReadCSV(file, func(ticket jira.JiraIssue) error {
+		ticket.Instance = *csvInstance
+		ticket.Project = jira.ProjectKey(ticket.Key)
+		ticket.MarkStale()
+		tickets = append(tickets, ticket)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := keepStored(boltDB, tickets); err != nil {
+		return err
+	}
+	if err := boltDB.Insert(ctx, tickets...); err != nil {
+		return fmt.Errorf("could not add issues to bolt: %v", err)
+	}
+	logger.Printf("imported %d tickets from %s\n", len(tickets), path)
+	return nil
+}
+
+// keepStored copies onto fetched tickets what only their stored versions hold, such as the hashes of downloaded
+// attachments and the sprints, so that storing them again does not lose it.
+func keepStored(boltDB *db.Bolt, tickets []jira.JiraIssue) error {
+	for i := range tickets {
+		stored, err := boltDB.TicketByKey(tickets[i].Instance, tickets[i].Key)
+		if err != nil {
+			return fmt.Errorf("could not get stored ticket %s: %v", tickets[i].Key, err)
+		}
+		if stored != nil {
+			tickets[i].KeepStored(*stored)
+		}
+	}
+	return nil
+}
+
+// importSprints records the sprints of every stored issue of a project of an instance, marking the updated
+// issues as stale.
+func importSprints(ctx context.Context, logger *log.Logger, client *jira.Client, boltDB *db.Bolt,
+	instance, project string) error {
+	issueSprints, err := client.IssueSprints(ctx, project)
+	if err != nil {
+		return err
+	}
+	var updated []jira.JiraIssue
+	for key, sprints := range issueSprints {
+		ticket, err := boltDB.TicketByKey(instance, key)
+		if err != nil {
+			return fmt.Errorf("could not get ticket %s from bolt: %v", key, err)
+		}
+		if ticket == nil {
+			continue
+		}
+		ticket.Sprints = sprints
+		ticket.MarkStale()
+		updated = append(updated, *ticket)
+	}
+	if err := boltDB.Insert(ctx, updated...); err != nil {
+		return fmt.Errorf("could not add sprints to bolt: %v", err)
+	}
+	logger.Printf("recorded sprints of %d tickets\n", len(updated))
+	return nil
+}
+
+// jiraAuthenticator returns the Jira authenticator for the method of an instance, configured from the
+// environment variables holding its credentials.
+func jiraAuthenticator(instance Instance) (jira.Authenticator, error) {
+	switch instance.Auth {
+	case "session":
+		return &jira.SessionAuthenticator{
+			Username: instance.env("JIRA_USERNAME"),
+			Password: instance.env("JIRA_PASSWORD"),
+		}, nil
+	case "basic":
+		return &jira.BasicAuthenticator{
+			Email:    instance.env("JIRA_EMAIL"),
+			APIToken: instance.env("JIRA_API_TOKEN"),
+		}, nil
+	case "bearer":
+		return &jira.BearerAuthenticator{
+			Token: instance.env("JIRA_PAT"),
+		}, nil
+	case "oauth":
+		pemBytes, err := ioutil.ReadFile(instance.env("JIRA_OAUTH_PRIVATE_KEY_PATH"))
+		if err != nil {
+			return nil, fmt.Errorf("could not read OAuth private key: %v", err)
+		}
+		privateKey, err := jira.ParseRSAPrivateKey(pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		return &jira.OAuthAuthenticator{
+			ConsumerKey: instance.env("JIRA_OAUTH_CONSUMER_KEY"),
+			PrivateKey:  privateKey,
+			AccessToken: instance.env("JIRA_OAUTH_ACCESS_TOKEN"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("%s is not a valid authentication method; available methods are "+
+			"session, basic, bearer and oauth", instance.Auth)
+	}
+}
+
+// report logs the pages still missing from a checkpoint and the keys missing versus the expected
+// number of tickets, returning whether every page has been fetched.
+func report(ctx context.Context, logger *log.Logger, client ticketSource, query string, expected int,
+	checkpoint *db.Checkpoint) bool {
+	fetched := make(map[string]bool)
+	var missingPages []int
+	for i := 0; i < checkpoint.Pages; i++ {
+		keys, done := checkpoint.Done[i]
+		if !done {
+			missingPages = append(missingPages, i)
+			continue
+		}
+		for _, key := range keys {
+			fetched[key] = true
+		}
+	}
+
+	logger.Printf("fetched %d out of %d tickets\n", len(fetched), expected)
+	for _, page := range missingPages {
+		logger.Printf("missing page %d (tickets %d to %d)\n",
+			page, page*checkpoint.PageSize, (page+1)*checkpoint.PageSize-1)
+	}
+
+	if lister, ok := client.(keyLister); ok && len(fetched) < expected {
+		keys, err := lister.TicketKeys(ctx, query)
+		if err != nil {
+			logger.Printf("could not list ticket keys: %v\n", err)
+		} else {
+			var missing []string
+			for _, key := range keys {
+				if !fetched[key] {
+					missing = append(missing, key)
+				}
+			}
+			if len(missing) > 0 {
+				logger.Printf("%d keys still missing: %s\n", len(missing), strings.Join(missing, ", "))
+			}
+		}
+	}
+
+	return len(missingPages) == 0
 }
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/nclandrei/ticketguru/db"
+	"github.com/nclandrei/ticketguru/jira"
+	"github.com/nclandrei/ticketguru/jiratest"
+)
+
+// crawl runs crawlProject for a project of a Jira instance served by a fake Jira from the fixtures, storing
+// the tickets into boltDB.
+func crawl(t *testing.T, boltDB *db.Bolt, instance Instance, project Project) {
+	t.Helper()
+	server, err := jiratest.NewServer("../../jiratest/testdata")
+	if err != nil {
+		t.Fatalf("could not start fake Jira: %v", err)
+	}
+	defer server.Close()
+	server.Username = "jira"
+	server.Password = "secret"
+	server.MaxEmbedded = 1
+
+	client, err := server.JiraClient()
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	if err := client.AuthenticateClient(context.Background()); err != nil {
+		t.Fatalf("could not authenticate client: %v", err)
+	}
+	logger := log.New(ioutil.Discard, "", 0)
+	if err := crawlProject(context.Background(), logger, boltDB, client, instance, project); err != nil {
+		t.Fatalf("could not crawl %s: %v", project.label(), err)
+	}
+}
+
+// newBolt opens a Bolt database in a temporary directory.
+func newBolt(t *testing.T) *db.Bolt {
+	t.Helper()
+	boltDB, err := db.NewBolt(filepath.Join(t.TempDir(), "issues.db"))
+	if err != nil {
+		t.Fatalf("could not create Bolt DB: %v", err)
+	}
+	t.Cleanup(func() { boltDB.Close() })
+	return boltDB
+}
+
+// projectKeys returns the sorted keys of the tickets stored in a project bucket.
+func projectKeys(t *testing.T, boltDB *db.Bolt, project string) []string {
+	t.Helper()
+	tickets, err := boltDB.ProjectTickets(project)
+	if err != nil {
+		t.Fatalf("could not get tickets of %s: %v", project, err)
+	}
+	var keys []string
+	for _, ticket := range tickets {
+		keys = append(keys, ticket.Key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestCrawlProject(t *testing.T) {
+	boltDB := newBolt(t)
+	instance := Instance{Name: "apache", Source: "jira", Concurrency: 2, PageSize: 2}
+	crawl(t, boltDB, instance, Project{Name: "KAFKA"})
+
+	tickets, err := boltDB.Tickets()
+	if err != nil {
+		t.Fatalf("could not get tickets: %v", err)
+	}
+	if len(tickets) != 3 {
+		t.Fatalf("got %d stored tickets, want 3", len(tickets))
+	}
+	for _, ticket := range tickets {
+		if ticket.Instance != "apache" || ticket.Project != "KAFKA" || !ticket.Stale {
+			t.Errorf("%s: got instance %q, project %q and stale %v, want apache, KAFKA and true",
+				ticket.Key, ticket.Instance, ticket.Project, ticket.Stale)
+		}
+	}
+
+	ticket, err := boltDB.TicketByKey("apache", "KAFKA-1")
+	if err != nil || ticket == nil {
+		t.Fatalf("could not get KAFKA-1: %v", err)
+	}
+	if len(ticket.Fields.Comments.Comments) != 3 || len(ticket.Changelog.Histories) != 3 ||
+		len(ticket.Fields.Worklogs.Worklogs) != 2 {
+		t.Errorf("got %d comments, %d histories and %d worklogs for KAFKA-1, want 3, 3 and 2",
+			len(ticket.Fields.Comments.Comments), len(ticket.Changelog.Histories),
+			len(ticket.Fields.Worklogs.Worklogs))
+	}
+
+	syncKey := "apache:" + jira.ProjectQuery("KAFKA")
+	checkpoint, err := boltDB.Checkpoint(syncKey)
+	if err != nil || checkpoint != nil {
+		t.Errorf("got checkpoint %v (%v) after a complete run, want none", checkpoint, err)
+	}
+	lastSync, err := boltDB.LastSync(syncKey)
+	if err != nil || lastSync.IsZero() {
+		t.Errorf("got no sync time after a complete run: %v", err)
+	}
+}
+
+func TestCrawlProjectJQL(t *testing.T) {
+	boltDB := newBolt(t)
+	instance := Instance{Name: "apache", Source: "jira", Concurrency: 2, PageSize: 2}
+	crawl(t, boltDB, instance, Project{JQL: "project in (KAFKA, HADOOP) AND status != Closed"})
+
+	for project, want := range map[string][]string{
+		"apache/KAFKA":  {"KAFKA-1", "KAFKA-2"},
+		"apache/HADOOP": {"HADOOP-1", "HADOOP-2"},
+	} {
+		if got := projectKeys(t, boltDB, project); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %s tickets %v, want %v", project, got, want)
+		}
+	}
+}
+
+func TestCrawlProjectInstancesSharingKeys(t *testing.T) {
+	boltDB := newBolt(t)
+	for _, name := range []string{"apache", "mirror"} {
+		crawl(t, boltDB, Instance{Name: name, Source: "jira", Concurrency: 1, PageSize: 50}, Project{Name: "KAFKA"})
+	}
+
+	for _, name := range []string{"apache", "mirror"} {
+		want := []string{"KAFKA-1", "KAFKA-2", "KAFKA-3"}
+		if got := projectKeys(t, boltDB, name+"/KAFKA"); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %s tickets %v, want %v", name, got, want)
+		}
+		ticket, err := boltDB.TicketByKey(name, "KAFKA-1")
+		if err != nil || ticket == nil || ticket.Instance != name {
+			t.Errorf("could not get KAFKA-1 of %s: %v", name, err)
+		}
+	}
+}
+
+func TestCrawlProjectKeepsStoredFields(t *testing.T) {
+	boltDB := newBolt(t)
+	instance := Instance{Name: "apache", Source: "jira", Concurrency: 1, PageSize: 50}
+	crawl(t, boltDB, instance, Project{Name: "KAFKA"})
+
+	ticket, err := boltDB.TicketByKey("apache", "KAFKA-1")
+	if err != nil || ticket == nil || len(ticket.Fields.Attachments) != 1 {
+		t.Fatalf("could not get the attachment of KAFKA-1: %v", err)
+	}
+	ticket.Fields.Attachments[0].SHA256 = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	ticket.Fields.Attachments[0].ContentSize = 2048
+	ticket.Sprints = []jira.Sprint{{ID: 71, Name: "Kafka Sprint 1", State: "closed"}}
+	if err := boltDB.Insert(context.Background(), *ticket); err != nil {
+		t.Fatalf("could not store KAFKA-1: %v", err)
+	}
+
+	crawl(t, boltDB, instance, Project{Name: "KAFKA"})
+
+	ticket, err = boltDB.TicketByKey("apache", "KAFKA-1")
+	if err != nil || ticket == nil || len(ticket.Fields.Attachments) != 1 {
+		t.Fatalf("could not get the attachment of KAFKA-1 after the second crawl: %v", err)
+	}
+	if a := ticket.Fields.Attachments[0]; a.SHA256 == "" || a.ContentSize != 2048 {
+		t.Errorf("got hash %q and size %d after the second crawl, want those of the download", a.SHA256, a.ContentSize)
+	}
+	if len(ticket.Sprints) != 1 || ticket.Sprints[0].ID != 71 {
+		t.Errorf("got sprints %v after a second crawl without -sprints, want the stored sprint", ticket.Sprints)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/joho/godotenv"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/nclandrei/ticketguru/analyze"
+	"github.com/nclandrei/ticketguru/db"
+	"github.com/nclandrei/ticketguru/jira"
+)
+
+const (
+	// maxPayloadSize defines the maximum size in bytes of an accepted webhook payload.
+	maxPayloadSize = 10 << 20
+	// shutdownTimeout defines how long pending events are waited for when shutting down.
+	shutdownTimeout = 30 * time.Second
+)
+
+var (
+	addr     = flag.String("addr", ":8080", "address the webhook server listens on")
+	path     = flag.String("path", "/webhook", "path Jira posts webhook payloads to")
+	dbPath   = flag.String("dbPath", "issues.db", "absolute path to the Bolt database")
+	instance = flag.String("instance", "jira", "name of the Jira instance sending the events")
+)
+
+// server merges the Jira webhook payloads it receives into the tickets stored in Bolt.
+type server struct {
+	db     *db.Bolt
+	secret string
+	logger *log.Logger
+	// lock serializes the read-modify-write cycle of stored tickets.
+	lock sync.Mutex
+}
+
+func main() {
+	flag.Parse()
+
+	logger := log.New(os.Stdout, "jira-webhook: ", log.Lshortfile)
+
+	err := godotenv.Load()
+	if err != nil {
+		logger.Fatalf("could not load .env file: %v\n", err)
+	}
+
+	secret := os.Getenv("JIRA_WEBHOOK_SECRET")
+	if secret == "" {
+		logger.Fatalf("JIRA_WEBHOOK_SECRET must be set\n")
+	}
+
+	boltDB, err := db.NewBolt(*dbPath)
+	if err != nil {
+		logger.Fatalf("could not create Bolt DB: %v\n", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(*path, &server{
+		db:     boltDB,
+		secret: secret,
+		logger: logger,
+	})
+	httpServer := &http.Server{Addr: *addr, Handler: mux}
+
+	interruptCh := make(chan os.Signal, 1)
+	signal.Notify(interruptCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interruptCh
+		logger.Printf("interrupt issued... waiting for pending events to be stored\n")
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(ctx); err != nil {
+			logger.Printf("could not shut down gracefully: %v\n", err)
+		}
+	}()
+
+	logger.Printf("listening on %s%s\n", *addr, *path)
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		logger.Fatal(err)
+	}
+	if err := boltDB.Close(); err != nil {
+		logger.Fatalf("could not close Bolt DB: %v\n", err)
+	}
+}
+
+// ServeHTTP validates a webhook payload, merges it into the stored ticket and reruns the local analyses on it.
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(w, "could not read payload", http.StatusBadRequest)
+		return
+	}
+	if !s.authorized(r, body) {
+		http.Error(w, "invalid secret", http.StatusUnauthorized)
+		return
+	}
+
+	var event jira.WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		s.logger.Printf("could not decode webhook payload: %v\n", err)
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if !event.Supported() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := s.apply(r.Context(), &event); err != nil {
+		s.logger.Printf("could not apply %s event: %v\n", event.WebhookEvent, err)
+		http.Error(w, "could not apply event", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// apply merges an event into the stored ticket and reruns the analyses that need no external service.
+// The ticket stays stale and, when its text changed, loses its scores, so that the next stale run of the
+// language analyses scores it again. Comment events on tickets not stored yet are skipped, as their payloads
+// only carry a partial issue.
+func (s *server) apply(ctx context.Context, event *jira.WebhookEvent) error {
+	if event.Issue == nil {
+		return fmt.Errorf("event carries no issue")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stored, err := s.db.TicketByKey(*instance, event.Issue.Key)
+	if err != nil {
+		return err
+	}
+	merged, err := event.Merge(stored)
+	if err == jira.ErrIssueNotStored {
+		s.logger.Printf("skipped %s event for %s: %v\n", event.WebhookEvent, event.Issue.Key, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if merged.Instance == "" {
+		merged.Instance = *instance
+		merged.Project = jira.ProjectKey(merged.Key)
+	}
+
+	tickets := []jira.JiraIssue{*merged}
+	for _, analysis := range []analyze.TicketAnalysis{
+		analyze.StackTraces,
+		analyze.StepsToReproduce,
+		analyze.FieldsComplexity,
+		analyze.CommentsComplexity,
+	} {
+		analysis(tickets...)
+	}
+	if err := s.db.Insert(ctx, tickets...); err != nil {
+		return err
+	}
+	s.logger.Printf("applied %s event to %s\n", event.WebhookEvent, merged.Key)
+	return nil
+}
+
+// authorized checks the shared secret of a request, either through the HMAC-SHA256 signature of the payload
+// sent by Jira Cloud in X-Hub-Signature or, for Jira Server, through the secret query parameter of the
+// registered webhook URL.
+func (s *server) authorized(r *http.Request, body []byte) bool {
+	if signature := r.Header.Get("X-Hub-Signature"); signature != "" {
+		mac := hmac.New(sha256.New, []byte(s.secret))
+		mac.Write(body)
+		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		return hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected))
+	}
+	secret := r.URL.Query().Get("secret")
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(s.secret)) == 1
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/nclandrei/ticketguru/jira"
@@ -9,15 +11,36 @@ import (
 	"github.com/boltdb/bolt"
 )
 
-// Name of the bucket where we'll be inserting our users.
 const (
-	bucketName = "users"
+	// ticketsBucketName holds a nested bucket of tickets per source instance and project.
+	ticketsBucketName = "tickets"
+	// keysBucketName maps the instance and key of every stored ticket onto the name of the project bucket
+	// holding it.
+	keysBucketName = "keys"
+	// syncBucketName holds the time of the last successful sync for each fetched query.
+	syncBucketName = "sync"
+	// checkpointBucketName holds the progress of fetch runs, so interrupted runs can be resumed.
+	checkpointBucketName = "checkpoints"
+	// metadataBucketName holds the schema version of the database.
+	metadataBucketName = "metadata"
+	// schemaVersionKey is the key of the schema version inside the metadata bucket.
+	schemaVersionKey = "schemaVersion"
 )
 
+// Checkpoint holds the progress of a paginated fetch run.
+type Checkpoint struct {
+	Query    string
+	Started  time.Time
+	PageSize int
+	Pages    int
+	// Done maps the index of each completed page to the keys of the tickets fetched with it.
+	Done map[int][]string
+}
+
 // TicketStorage defines a generic interface for different DBs to implement.
 type TicketStorage interface {
 	Tickets() ([]jira.JiraIssue, error)
-	Insert(...jira.JiraIssue) error
+	Insert(context.Context, ...jira.JiraIssue) error
 	Slice(int, int) ([]jira.JiraIssue, error)
 	Size() (int, error)
 }
@@ -27,7 +50,7 @@ type Bolt struct {
 	*bolt.DB
 }
 
-// NewBolt returns a new Bolt Database instance.
+// NewBolt returns a new Bolt Database instance, upgrading the database to the current schema version first.
 func NewBolt(path string) (*Bolt, error) {
 	options := &bolt.Options{
 		Timeout: 20 * time.Second,
@@ -37,31 +60,48 @@ func NewBolt(path string) (*Bolt, error) {
 		return nil, err
 	}
 	err = db.Update(func(tx *bolt.Tx) error {
-		_, txErr := tx.CreateBucketIfNotExists([]byte(bucketName))
-		return txErr
+		for _, name := range []string{
+			ticketsBucketName, keysBucketName, syncBucketName, checkpointBucketName, metadataBucketName,
+		} {
+			if _, txErr := tx.CreateBucketIfNotExists([]byte(name)); txErr != nil {
+				return txErr
+			}
+		}
+		return nil
 	})
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not migrate database: %v", err)
+	}
 	return &Bolt{
 		DB: db,
 	}, err
 }
 
-// Insert takes a slice of tickets and inserts them into Bolt.
-func (db *Bolt) Insert(tickets ...jira.JiraIssue) error {
+// Insert takes a slice of tickets and inserts them into Bolt, one transaction per ticket. Once ctx is
+// cancelled, the transaction in progress is committed and the remaining tickets are left out.
+func (db *Bolt) Insert(ctx context.Context, tickets ...jira.JiraIssue) error {
 	for _, ticket := range tickets {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		tx, err := db.Begin(true)
 		if err != nil {
 			return fmt.Errorf("could not create transaction: %v", err)
 		}
-		b := tx.Bucket([]byte(bucketName))
 		buf, err := json.Marshal(&ticket)
 		if err != nil {
+			tx.Rollback()
 			return fmt.Errorf("could not marshal ticket %s: %v", ticket.Key, err)
 		}
-		err = b.Put([]byte(ticket.Key), buf)
+		err = putTicket(tx, ticket.Instance, ticket.Key, projectBucket(ticket.Instance, ticket.Project, ticket.Key),
+			buf)
 		if err != nil {
+			tx.Rollback()
 			return fmt.Errorf("could not insert ticket %s: %v", ticket.Key, err)
 		}
 		if err = tx.Commit(); err != nil {
@@ -71,22 +111,44 @@ func (db *Bolt) Insert(tickets ...jira.JiraIssue) error {
 	return nil
 }
 
-// TicketByKey returns a single ticket searched for by key.
-func (db *Bolt) TicketByKey(key string) (*jira.JiraIssue, error) {
+// InsertMany inserts a slice of tickets into Bolt within a single transaction, so that either all of them or none
+// are stored. It suits bulk imports, which would otherwise commit, and sync to disk, once per ticket.
+func (db *Bolt) InsertMany(ctx context.Context, tickets ...jira.JiraIssue) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		for _, ticket := range tickets {
+			buf, err := json.Marshal(&ticket)
+			if err != nil {
+				return fmt.Errorf("could not marshal ticket %s: %v", ticket.Key, err)
+			}
+			err = putTicket(tx, ticket.Instance, ticket.Key, projectBucket(ticket.Instance, ticket.Project, ticket.Key),
+				buf)
+			if err != nil {
+				return fmt.Errorf("could not insert ticket %s: %v", ticket.Key, err)
+			}
+		}
+		return nil
+	})
+}
+
+// TicketByKey returns a single ticket of an instance searched for by key. Tickets stored without an instance,
+// before instances were recorded, are returned when the instance has no ticket with that key.
+func (db *Bolt) TicketByKey(instance, key string) (*jira.JiraIssue, error) {
 	tx, err := db.Begin(false)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
-	b := tx.Bucket([]byte(bucketName))
-	if b == nil {
-		return nil, fmt.Errorf("could not retrieve users bucket from bolt")
+	bTicket, err := getTicket(tx, indexKey(instance, key))
+	if err == nil && bTicket == nil && instance != "" {
+		bTicket, err = getTicket(tx, indexKey("", key))
 	}
-	var ticket *jira.JiraIssue
-	bTicket := b.Get([]byte(key))
-	if bTicket == nil {
-		return nil, nil
+	if err != nil || bTicket == nil {
+		return nil, err
 	}
+	var ticket *jira.JiraIssue
 	err = json.Unmarshal(bTicket, &ticket)
 	if err != nil {
 		return nil, err
@@ -94,30 +156,60 @@ func (db *Bolt) TicketByKey(key string) (*jira.JiraIssue, error) {
 	return ticket, nil
 }
 
-// Tickets retrieves all the tickets from inside the database.
+// Tickets retrieves all the tickets from inside the database, grouped by project.
 func (db *Bolt) Tickets() ([]jira.JiraIssue, error) {
 	var tickets []jira.JiraIssue
-	tx, err := db.Begin(false)
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Rollback()
-	b := tx.Bucket([]byte(bucketName))
-	if b == nil {
-		return nil, fmt.Errorf("could not retrieve users bucket from bolt")
-	}
-	err = b.ForEach(func(k, v []byte) error {
-		var ticket jira.JiraIssue
-		err := json.Unmarshal(v, &ticket)
-		if err == nil {
-			tickets = append(tickets, ticket)
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ticketsBucketName))
+		if b == nil {
+			return fmt.Errorf("could not retrieve tickets bucket from bolt")
 		}
+		return b.ForEach(func(name, v []byte) error {
+			project := b.Bucket(name)
+			if project == nil {
+				return nil
+			}
+			projectTickets, err := unmarshalTickets(project)
+			tickets = append(tickets, projectTickets...)
+			return err
+		})
+	})
+	return tickets, err
+}
+
+// Projects returns the names of the project buckets, each holding the tickets of a project of a source instance.
+func (db *Bolt) Projects() ([]string, error) {
+	var projects []string
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ticketsBucketName))
+		if b == nil {
+			return fmt.Errorf("could not retrieve tickets bucket from bolt")
+		}
+		return b.ForEach(func(name, v []byte) error {
+			if v == nil {
+				projects = append(projects, string(name))
+			}
+			return nil
+		})
+	})
+	return projects, err
+}
+
+// ProjectTickets retrieves the tickets stored in a project bucket.
+func (db *Bolt) ProjectTickets(project string) ([]jira.JiraIssue, error) {
+	var tickets []jira.JiraIssue
+	err := db.View(func(tx *bolt.Tx) error {
+		b, err := ticketBucket(tx, project)
+		if err != nil {
+			return err
+		}
+		tickets, err = unmarshalTickets(b)
 		return err
 	})
 	return tickets, err
 }
 
-// Slice returns a ticket slice given a low and high bound.
+// Slice returns a ticket slice given a low and high bound, the tickets being ordered by instance and key.
 func (db *Bolt) Slice(l, h int) ([]jira.JiraIssue, error) {
 	if l >= h {
 		return nil, fmt.Errorf("low bound is greater than high bound")
@@ -134,22 +226,25 @@ func (db *Bolt) Slice(l, h int) ([]jira.JiraIssue, error) {
 	}
 	tickets := make([]jira.JiraIssue, h-l)
 	err = db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(bucketName))
-		cursor := b.Cursor()
-		_, v := cursor.First()
+		cursor := tx.Bucket([]byte(keysBucketName)).Cursor()
+		k, _ := cursor.First()
 		var i int
 		for i < l {
-			_, v = cursor.Next()
+			k, _ = cursor.Next()
 			i++
 		}
 		for i < h {
+			v, err := getTicket(tx, k)
+			if err != nil {
+				return err
+			}
 			var ticket jira.JiraIssue
-			err := json.Unmarshal(v, &ticket)
+			err = json.Unmarshal(v, &ticket)
 			if err != nil {
 				return err
 			}
 			tickets[i-l] = ticket
-			_, v = cursor.Next()
+			k, _ = cursor.Next()
 			i++
 		}
 		return nil
@@ -157,25 +252,248 @@ func (db *Bolt) Slice(l, h int) ([]jira.JiraIssue, error) {
 	return tickets, err
 }
 
-// Cursor returns a cursor to the users inside the bucket as well as a function to close the open tx.
-func (db *Bolt) Cursor() (*bolt.Cursor, func() error, error) {
+// Cursor returns a cursor to the tickets inside a project bucket as well as a function to close the open tx.
+func (db *Bolt) Cursor(project string) (*bolt.Cursor, func() error, error) {
 	tx, err := db.Begin(false)
 	if err != nil {
 		return nil, nil, err
 	}
-	b := tx.Bucket([]byte(bucketName))
+	b, err := ticketBucket(tx, project)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
 	teardown := func() error {
 		return tx.Rollback()
 	}
 	return b.Cursor(), teardown, nil
 }
 
-// Size returns the total number of key/value pairs inside the tickets bucket.
+// Size returns the total number of tickets inside the database.
 func (db *Bolt) Size() (int, error) {
 	tx, err := db.Begin(false)
 	if err != nil {
 		return -1, err
 	}
 	defer tx.Rollback()
-	return tx.Bucket([]byte(bucketName)).Stats().KeyN, nil
+	return tx.Bucket([]byte(keysBucketName)).Stats().KeyN, nil
+}
+
+// StaleTickets retrieves the tickets whose derived analysis fields need recomputing.
+func (db *Bolt) StaleTickets() ([]jira.JiraIssue, error) {
+	tickets, err := db.Tickets()
+	if err != nil {
+		return nil, err
+	}
+	var stale []jira.JiraIssue
+	for _, ticket := range tickets {
+		if ticket.Stale {
+			stale = append(stale, ticket)
+		}
+	}
+	return stale, nil
+}
+
+// LastSync returns the time of the last successful sync for a key, or the zero time if it was never synced.
+func (db *Bolt) LastSync(key string) (time.Time, error) {
+	var lastSync time.Time
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(syncBucketName))
+		if b == nil {
+			return fmt.Errorf("could not retrieve sync bucket from bolt")
+		}
+		v := b.Get([]byte(key))
+		if v == nil {
+			return nil
+		}
+		return lastSync.UnmarshalText(v)
+	})
+	return lastSync, err
+}
+
+// SetLastSync records the time of the last successful sync for a key.
+func (db *Bolt) SetLastSync(key string, t time.Time) error {
+	buf, err := t.MarshalText()
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(syncBucketName))
+		if b == nil {
+			return fmt.Errorf("could not retrieve sync bucket from bolt")
+		}
+		return b.Put([]byte(key), buf)
+	})
+}
+
+// Checkpoint returns the checkpoint stored for a key, or nil if there is none.
+func (db *Bolt) Checkpoint(key string) (*Checkpoint, error) {
+	var checkpoint *Checkpoint
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(checkpointBucketName))
+		if b == nil {
+			return fmt.Errorf("could not retrieve checkpoints bucket from bolt")
+		}
+		v := b.Get([]byte(key))
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, &checkpoint)
+	})
+	return checkpoint, err
+}
+
+// SaveCheckpoint stores a checkpoint for a key, replacing any previous one.
+func (db *Bolt) SaveCheckpoint(key string, checkpoint Checkpoint) error {
+	buf, err := json.Marshal(&checkpoint)
+	if err != nil {
+		return fmt.Errorf("could not marshal checkpoint %s: %v", key, err)
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(checkpointBucketName))
+		if b == nil {
+			return fmt.Errorf("could not retrieve checkpoints bucket from bolt")
+		}
+		return b.Put([]byte(key), buf)
+	})
+}
+
+// MarkPageDone records a page of the checkpoint stored for a key as completed.
+func (db *Bolt) MarkPageDone(key string, page int, ticketKeys []string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(checkpointBucketName))
+		if b == nil {
+			return fmt.Errorf("could not retrieve checkpoints bucket from bolt")
+		}
+		v := b.Get([]byte(key))
+		if v == nil {
+			return fmt.Errorf("no checkpoint stored for %s", key)
+		}
+		var checkpoint Checkpoint
+		if err := json.Unmarshal(v, &checkpoint); err != nil {
+			return err
+		}
+		if checkpoint.Done == nil {
+			checkpoint.Done = make(map[int][]string)
+		}
+		checkpoint.Done[page] = ticketKeys
+		buf, err := json.Marshal(&checkpoint)
+		if err != nil {
+			return fmt.Errorf("could not marshal checkpoint %s: %v", key, err)
+		}
+		return b.Put([]byte(key), buf)
+	})
+}
+
+// ClearCheckpoint removes the checkpoint stored for a key.
+func (db *Bolt) ClearCheckpoint(key string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(checkpointBucketName))
+		if b == nil {
+			return fmt.Errorf("could not retrieve checkpoints bucket from bolt")
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// projectBucket returns the name of the bucket a ticket is stored in: its source instance and project, the
+// project defaulting to the one of its key. Tickets stored without an instance, before instances were recorded,
+// are stored under their project alone.
+func projectBucket(instance, project, key string) string {
+	if project == "" {
+		project = jira.ProjectKey(key)
+	}
+	if instance == "" {
+		return project
+	}
+	return instance + "/" + project
+}
+
+// indexKey returns the key of a ticket in the keys bucket, so that instances sharing a ticket key, such as two
+// Jira instances with a KAFKA project, each keep their own copy.
+func indexKey(instance, key string) []byte {
+	return []byte(instance + "\x00" + key)
+}
+
+// ticketBucket returns a project bucket within a transaction.
+func ticketBucket(tx *bolt.Tx, name string) (*bolt.Bucket, error) {
+	b := tx.Bucket([]byte(ticketsBucketName))
+	if b == nil {
+		return nil, fmt.Errorf("could not retrieve tickets bucket from bolt")
+	}
+	project := b.Bucket([]byte(name))
+	if project == nil {
+		return nil, fmt.Errorf("could not retrieve bucket of project %s from bolt", name)
+	}
+	return project, nil
+}
+
+// getTicket returns the stored value of the ticket with the given index key within a transaction, or nil if
+// there is none.
+func getTicket(tx *bolt.Tx, index []byte) ([]byte, error) {
+	name := tx.Bucket([]byte(keysBucketName)).Get(index)
+	if name == nil {
+		return nil, nil
+	}
+	b, err := ticketBucket(tx, string(name))
+	if err != nil {
+		return nil, err
+	}
+	key := index[bytes.IndexByte(index, 0)+1:]
+	return b.Get(key), nil
+}
+
+// putTicket stores the value of a ticket of an instance into a project bucket within a transaction, removing it
+// from the bucket it was previously stored in, if any. A copy stored without an instance, before instances were
+// recorded, is superseded and removed as well.
+func putTicket(tx *bolt.Tx, instance, key, project string, value []byte) error {
+	keys := tx.Bucket([]byte(keysBucketName))
+	index := indexKey(instance, key)
+	if previous := keys.Get(index); previous != nil && string(previous) != project {
+		if err := deleteTicket(tx, string(previous), key); err != nil {
+			return err
+		}
+	}
+	if instance != "" {
+		legacy := indexKey("", key)
+		if previous := keys.Get(legacy); previous != nil {
+			if err := deleteTicket(tx, string(previous), key); err != nil {
+				return err
+			}
+			if err := keys.Delete(legacy); err != nil {
+				return err
+			}
+		}
+	}
+	b, err := tx.Bucket([]byte(ticketsBucketName)).CreateBucketIfNotExists([]byte(project))
+	if err != nil {
+		return err
+	}
+	if err := b.Put([]byte(key), value); err != nil {
+		return err
+	}
+	return keys.Put(index, []byte(project))
+}
+
+// deleteTicket removes a ticket from a project bucket within a transaction, if the bucket exists.
+func deleteTicket(tx *bolt.Tx, project, key string) error {
+	b := tx.Bucket([]byte(ticketsBucketName)).Bucket([]byte(project))
+	if b == nil {
+		return nil
+	}
+	return b.Delete([]byte(key))
+}
+
+// unmarshalTickets returns every ticket stored in a project bucket.
+func unmarshalTickets(b *bolt.Bucket) ([]jira.JiraIssue, error) {
+	var tickets []jira.JiraIssue
+	err := b.ForEach(func(k, v []byte) error {
+		var ticket jira.JiraIssue
+		err := json.Unmarshal(v, &ticket)
+		if err == nil {
+			tickets = append(tickets, ticket)
+		}
+		return err
+	})
+	return tickets, err
 }
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/boltdb/bolt"
+)
+
+// legacyBucketName is the bucket all tickets were stored in up to schema version 1.
+const legacyBucketName = "users"
+
+// migration upgrades the layout or the stored tickets of a database by one schema version.
+type migration func(tx *bolt.Tx) error
+
+// migrations lists the upgrades between schema versions: migrations[i] upgrades a database from version i+1
+// to version i+2. Changes to the layout, or to JiraIssue fields that would not decode from the JSON already
+// stored, must come with a new migration appended here.
+var migrations = []migration{
+	migrateProjectBuckets,
+}
+
+// currentSchemaVersion is the version of the database layout and ticket schema written by this package.
+var currentSchemaVersion = len(migrations) + 1
+
+// migrate upgrades a database to the current schema version, running each pending migration in its own
+// transaction along with the update of the version. Databases without a version predate versioning and
+// are at version 1; databases written by a newer version of the package are rejected.
+func migrate(db *bolt.DB) error {
+	version, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if version > currentSchemaVersion {
+		return fmt.Errorf("database schema version %d is newer than the supported version %d", version,
+			currentSchemaVersion)
+	}
+	for ; version < currentSchemaVersion; version++ {
+		err := db.Update(func(tx *bolt.Tx) error {
+			if err := migrations[version-1](tx); err != nil {
+				return err
+			}
+			return tx.Bucket([]byte(metadataBucketName)).Put([]byte(schemaVersionKey),
+				[]byte(strconv.Itoa(version+1)))
+		})
+		if err != nil {
+			return fmt.Errorf("could not upgrade schema from version %d to %d: %v", version, version+1, err)
+		}
+	}
+	return nil
+}
+
+// schemaVersion returns the schema version recorded in the metadata bucket, or 1 if there is none.
+func schemaVersion(db *bolt.DB) (int, error) {
+	version := 1
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(metadataBucketName))
+		if b == nil {
+			return fmt.Errorf("could not retrieve metadata bucket from bolt")
+		}
+		v := b.Get([]byte(schemaVersionKey))
+		if v == nil {
+			return nil
+		}
+		var err error
+		version, err = strconv.Atoi(string(v))
+		if err != nil {
+			return fmt.Errorf("could not parse schema version %q: %v", v, err)
+		}
+		return nil
+	})
+	return version, err
+}
+
+// migrateProjectBuckets moves the tickets of the legacy users bucket into per project buckets.
+func migrateProjectBuckets(tx *bolt.Tx) error {
+	users := tx.Bucket([]byte(legacyBucketName))
+	if users == nil {
+		return nil
+	}
+	err := users.ForEach(func(k, v []byte) error {
+		var ticket struct {
+			Instance string `json:"instance"`
+			Project  string `json:"project"`
+		}
+		if err := json.Unmarshal(v, &ticket); err != nil {
+			return fmt.Errorf("could not unmarshal ticket %s: %v", k, err)
+		}
+		return putTicket(tx, ticket.Instance, string(k), projectBucket(ticket.Instance, ticket.Project, string(k)), v)
+	})
+	if err != nil {
+		return err
+	}
+	return tx.DeleteBucket([]byte(legacyBucketName))
+}
@@ -0,0 +1,332 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nclandrei/ticketguru/jira"
+)
+
+// perPage is the maximum page size accepted by the GitHub REST API.
+const perPage = 100
+
+// linkNextRegex extracts the URL of the next page from a Link header.
+var linkNextRegex = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// Client defines the client for the GitHub REST API.
+type Client struct {
+	*http.Client
+	URL   *url.URL
+	token string
+	// TokenEnv names the environment variable the token is read from.
+	TokenEnv string
+	// PriorityLabels maps normalized label tokens onto Jira priority IDs.
+	PriorityLabels map[string]string
+}
+
+// User defines a GitHub user as embedded in issues, comments and events.
+type User struct {
+	Login string `json:"login"`
+}
+
+// Label defines a GitHub issue label.
+type Label struct {
+	Name string `json:"name"`
+}
+
+// Issue defines a GitHub issue as returned by the issues endpoint.
+type Issue struct {
+	Number      int        `json:"number"`
+	HTMLURL     string     `json:"html_url"`
+	Title       string     `json:"title"`
+	Body        string     `json:"body"`
+	State       string     `json:"state"`
+	User        User       `json:"user"`
+	Labels      []Label    `json:"labels"`
+	Comments    int        `json:"comments"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ClosedAt    *time.Time `json:"closed_at"`
+	PullRequest *struct{}  `json:"pull_request"`
+}
+
+// Comment defines a comment left on a GitHub issue.
+type Comment struct {
+	ID        int       `json:"id"`
+	Body      string    `json:"body"`
+	User      User      `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TimelineEvent defines an event from the timeline of a GitHub issue.
+type TimelineEvent struct {
+	ID        int       `json:"id"`
+	Event     string    `json:"event"`
+	Actor     User      `json:"actor"`
+	Label     Label     `json:"label"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// searchResponse defines the subset of the search endpoint response used for counting issues.
+type searchResponse struct {
+	TotalCount int `json:"total_count"`
+}
+
+// NewClient returns a new GitHub Client; url should point to the API root (e.g. https://api.github.com).
+func NewClient(url *url.URL) (*Client, error) {
+	transport := &http.Transport{
+		Dial: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).Dial,
+		TLSHandshakeTimeout: 60 * time.Second,
+	}
+
+	return &Client{
+		Client: &http.Client{
+			Timeout:   time.Minute * 3,
+			Transport: transport,
+		},
+		URL:            url,
+		TokenEnv:       "GITHUB_TOKEN",
+		PriorityLabels: jira.DefaultPriorityLabels,
+	}, nil
+}
+
+// AuthenticateClient sets the token used by the client from the environment variable named by TokenEnv.
+// Public repositories can be queried anonymously, albeit with a much lower rate limit.
+func (client *Client) AuthenticateClient(ctx context.Context) error {
+	client.token = os.Getenv(client.TokenEnv)
+	return nil
+}
+
+// endpoint returns the absolute URL for a REST path and query without mutating the client URL.
+func (client *Client) endpoint(path string, query url.Values) string {
+	u := *client.URL
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// get performs a GET request against the GitHub API, decodes the JSON response into v and returns
+// the URL of the next page as advertised by the Link header, if any.
+func (client *Client) get(ctx context.Context, rawURL string, v interface{}) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Add("Accept", "application/vnd.github+json")
+	if client.token != "" {
+		request.Header.Add("Authorization", "Bearer "+client.token)
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status code different than 200 for %s: %v", rawURL, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", err
+	}
+	return nextPage(resp.Header.Get("Link")), nil
+}
+
+// nextPage returns the URL marked with rel="next" inside a Link header.
+func nextPage(link string) string {
+	match := linkNextRegex.FindStringSubmatch(link)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// Tickets returns a paginated slice of issues for a repository given as owner/name, converted into Jira issues.
+// Pagination runs over issues and pull requests alike, as the issues endpoint does; pull requests are dropped.
+func (client *Client) Tickets(
+	ctx context.Context,
+	repository string,
+	paginationIndex int,
+	pageCount int) ([]jira.JiraIssue, error) {
+
+	offset := paginationIndex * pageCount
+	query := make(url.Values)
+	query.Add("state", "all")
+	query.Add("sort", "created")
+	query.Add("direction", "asc")
+	query.Add("per_page", strconv.Itoa(perPage))
+	query.Add("page", strconv.Itoa(offset/perPage+1))
+	next := client.endpoint("/repos/"+repository+"/issues", query)
+	skip := offset % perPage
+
+	var raw []Issue
+	for next != "" && len(raw) < pageCount {
+		var page []Issue
+		var err error
+		next, err = client.get(ctx, next, &page)
+		if err != nil {
+			return nil, err
+		}
+		if skip > len(page) {
+			skip = len(page)
+		}
+		raw = append(raw, page[skip:]...)
+		skip = 0
+	}
+	if len(raw) > pageCount {
+		raw = raw[:pageCount]
+	}
+
+	var issues []jira.JiraIssue
+	for _, issue := range raw {
+		if issue.PullRequest != nil {
+			continue
+		}
+		converted, err := client.ticket(ctx, repository, issue)
+		if err != nil {
+			return issues, fmt.Errorf("could not retrieve issue %s#%d: %v", repository, issue.Number, err)
+		}
+		issues = append(issues, converted)
+	}
+	return issues, nil
+}
+
+// TicketsCount returns the total number of issues and pull requests for a repository, which is the
+// space Tickets paginates over.
+func (client *Client) TicketsCount(ctx context.Context, repository string) (int, error) {
+	query := make(url.Values)
+	query.Add("q", "repo:"+repository)
+	query.Add("per_page", "1")
+	var response searchResponse
+	if _, err := client.get(ctx, client.endpoint("/search/issues", query), &response); err != nil {
+		return -1, err
+	}
+	return response.TotalCount, nil
+}
+
+// ticket fetches every comment and timeline event of an issue and converts everything into a Jira issue.
+func (client *Client) ticket(ctx context.Context, repository string, issue Issue) (jira.JiraIssue, error) {
+	query := make(url.Values)
+	query.Add("per_page", strconv.Itoa(perPage))
+	issuePath := "/repos/" + repository + "/issues/" + strconv.Itoa(issue.Number)
+
+	var comments []Comment
+	for next := client.endpoint(issuePath+"/comments", query); next != ""; {
+		var page []Comment
+		var err error
+		next, err = client.get(ctx, next, &page)
+		if err != nil {
+			return jira.JiraIssue{}, err
+		}
+		comments = append(comments, page...)
+	}
+
+	var events []TimelineEvent
+	for next := client.endpoint(issuePath+"/timeline", query); next != ""; {
+		var page []TimelineEvent
+		var err error
+		next, err = client.get(ctx, next, &page)
+		if err != nil {
+			return jira.JiraIssue{}, err
+		}
+		events = append(events, page...)
+	}
+
+	return client.convert(repository, issue, comments, events), nil
+}
+
+// convert maps a GitHub issue along with its comments and timeline events into a Jira issue.
+func (client *Client) convert(repository string, issue Issue, comments []Comment, events []TimelineEvent) jira.JiraIssue {
+	converted := jira.JiraIssue{
+		Key:  fmt.Sprintf("%s#%d", repository, issue.Number),
+		ID:   strconv.Itoa(issue.Number),
+		Self: issue.HTMLURL,
+		Fields: jira.Fields{
+			Summary:     issue.Title,
+			Description: issue.Body,
+			Created:     jira.Time(issue.CreatedAt),
+			Status:      jira.Status{Name: statusName(issue.State)},
+			Priority:    client.priority(issue.Labels),
+			Type:        jira.Type{Name: issueType(issue.Labels)},
+		},
+	}
+
+	for _, l := range issue.Labels {
+		converted.Fields.Labels = append(converted.Fields.Labels, l.Name)
+	}
+
+	for _, c := range comments {
+		converted.Fields.Comments.Comments = append(converted.Fields.Comments.Comments, jira.Comment{
+			ID:      strconv.Itoa(c.ID),
+			Body:    c.Body,
+			Author:  jira.Author{Name: c.User.Login, DisplayName: c.User.Login},
+			Created: jira.Time(c.CreatedAt),
+			Updated: jira.Time(c.UpdatedAt),
+		})
+	}
+
+	for _, e := range events {
+		var item jira.ChangelogHistoryItem
+		switch e.Event {
+		case "closed":
+			item = jira.ChangelogHistoryItem{Field: "status", FromString: "Open", ToString: "Closed"}
+		case "reopened":
+			item = jira.ChangelogHistoryItem{Field: "status", FromString: "Closed", ToString: "Open"}
+		case "labeled":
+			item = jira.ChangelogHistoryItem{Field: "labels", ToString: e.Label.Name}
+		case "unlabeled":
+			item = jira.ChangelogHistoryItem{Field: "labels", FromString: e.Label.Name}
+		default:
+			continue
+		}
+		item.FieldType = "github"
+		converted.Changelog.Histories = append(converted.Changelog.Histories, jira.ChangelogHistory{
+			ID:      strconv.Itoa(e.ID),
+			Author:  jira.Author{Name: e.Actor.Login, DisplayName: e.Actor.Login},
+			Created: jira.Time(e.CreatedAt),
+			Items:   []jira.ChangelogHistoryItem{item},
+		})
+	}
+	converted.Changelog.MaxResults = len(converted.Changelog.Histories)
+	converted.Changelog.Total = len(converted.Changelog.Histories)
+
+	return converted
+}
+
+// priority derives a Jira priority from the issue labels.
+func (client *Client) priority(labels []Label) jira.Priority {
+	names := make([]string, len(labels))
+	for i, l := range labels {
+		names[i] = l.Name
+	}
+	return jira.PriorityFromLabels(names, client.PriorityLabels)
+}
+
+// statusName maps GitHub issue states onto Jira status names.
+func statusName(state string) string {
+	if state == "closed" {
+		return "Closed"
+	}
+	return "Open"
+}
+
+// issueType returns "Bug" if any label marks the issue as a bug and "Issue" otherwise.
+func issueType(labels []Label) string {
+	for _, l := range labels {
+		if strings.Contains(strings.ToLower(l.Name), "bug") {
+			return "Bug"
+		}
+	}
+	return "Issue"
+}
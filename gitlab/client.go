@@ -0,0 +1,341 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nclandrei/ticketguru/jira"
+)
+
+const (
+	// apiPath is the prefix of every GitLab v4 REST endpoint.
+	apiPath = "/api/v4"
+	// perPage is the maximum page size accepted by the GitLab REST API.
+	perPage = 100
+)
+
+// Client defines the client for the GitLab v4 REST API.
+type Client struct {
+	*http.Client
+	URL   *url.URL
+	token string
+	// TokenEnv names the environment variable the token is read from.
+	TokenEnv string
+	// PriorityLabels maps normalized label tokens onto Jira priority IDs.
+	PriorityLabels map[string]string
+}
+
+// User defines a GitLab user as embedded in issues, notes and events.
+type User struct {
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+// Issue defines a GitLab issue as returned by the project issues endpoint.
+type Issue struct {
+	ID          int        `json:"id"`
+	IID         int        `json:"iid"`
+	WebURL      string     `json:"web_url"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	State       string     `json:"state"`
+	Author      User       `json:"author"`
+	Labels      []string   `json:"labels"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ClosedAt    *time.Time `json:"closed_at"`
+	DueDate     string     `json:"due_date"`
+	IssueType   string     `json:"issue_type"`
+	TimeStats   struct {
+		TimeEstimate   int `json:"time_estimate"`
+		TotalTimeSpent int `json:"total_time_spent"`
+	} `json:"time_stats"`
+}
+
+// Note defines a note (comment) left on a GitLab issue; system notes record events such as closing.
+type Note struct {
+	ID        int       `json:"id"`
+	Body      string    `json:"body"`
+	Author    User      `json:"author"`
+	System    bool      `json:"system"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StateEvent defines a resource state event of a GitLab issue.
+type StateEvent struct {
+	ID        int       `json:"id"`
+	User      User      `json:"user"`
+	State     string    `json:"state"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// statisticsResponse defines the subset of the issues statistics response used for counting issues.
+type statisticsResponse struct {
+	Statistics struct {
+		Counts struct {
+			All int `json:"all"`
+		} `json:"counts"`
+	} `json:"statistics"`
+}
+
+// NewClient returns a new GitLab Client; url should point to the GitLab instance root (e.g. https://gitlab.com).
+func NewClient(url *url.URL) (*Client, error) {
+	transport := &http.Transport{
+		Dial: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).Dial,
+		TLSHandshakeTimeout: 60 * time.Second,
+	}
+
+	return &Client{
+		Client: &http.Client{
+			Timeout:   time.Minute * 3,
+			Transport: transport,
+		},
+		URL:            url,
+		TokenEnv:       "GITLAB_TOKEN",
+		PriorityLabels: jira.DefaultPriorityLabels,
+	}, nil
+}
+
+// AuthenticateClient sets the personal access token used by the client from the environment
+// variable named by TokenEnv.
+func (client *Client) AuthenticateClient(ctx context.Context) error {
+	client.token = os.Getenv(client.TokenEnv)
+	return nil
+}
+
+// endpoint returns the absolute URL for a project scoped REST path and query without mutating the client URL.
+// The project path (e.g. group/service) is escaped as a single path segment, as GitLab expects.
+func (client *Client) endpoint(project, path string, query url.Values) string {
+	u := *client.URL
+	base := strings.TrimSuffix(u.Path, "/") + apiPath + "/projects/"
+	u.Path = base + project + path
+	u.RawPath = base + url.PathEscape(project) + path
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// get performs a GET request against the GitLab API, decodes the JSON response into v and returns
+// the number of the next page as advertised by the X-Next-Page header, or 0 on the last page.
+func (client *Client) get(ctx context.Context, rawURL string, v interface{}) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Add("Accept", "application/json")
+	if client.token != "" {
+		request.Header.Add("PRIVATE-TOKEN", client.token)
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("status code different than 200 for %s: %v", rawURL, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return 0, err
+	}
+	next, _ := strconv.Atoi(resp.Header.Get("X-Next-Page"))
+	return next, nil
+}
+
+// Tickets returns a paginated slice of issues for a project given by its ID or full path, converted into Jira issues.
+func (client *Client) Tickets(
+	ctx context.Context,
+	project string,
+	paginationIndex int,
+	pageCount int) ([]jira.JiraIssue, error) {
+
+	offset := paginationIndex * pageCount
+	query := make(url.Values)
+	query.Add("scope", "all")
+	query.Add("state", "all")
+	query.Add("order_by", "created_at")
+	query.Add("sort", "asc")
+	query.Add("per_page", strconv.Itoa(perPage))
+	page := offset/perPage + 1
+	skip := offset % perPage
+
+	var raw []Issue
+	for page != 0 && len(raw) < pageCount {
+		query.Set("page", strconv.Itoa(page))
+		var issues []Issue
+		var err error
+		page, err = client.get(ctx, client.endpoint(project, "/issues", query), &issues)
+		if err != nil {
+			return nil, err
+		}
+		if skip > len(issues) {
+			skip = len(issues)
+		}
+		raw = append(raw, issues[skip:]...)
+		skip = 0
+	}
+	if len(raw) > pageCount {
+		raw = raw[:pageCount]
+	}
+
+	issues := make([]jira.JiraIssue, 0, len(raw))
+	for _, issue := range raw {
+		converted, err := client.ticket(ctx, project, issue)
+		if err != nil {
+			return issues, fmt.Errorf("could not retrieve issue %s#%d: %v", project, issue.IID, err)
+		}
+		issues = append(issues, converted)
+	}
+	return issues, nil
+}
+
+// TicketsCount returns the total number of issues for a project.
+func (client *Client) TicketsCount(ctx context.Context, project string) (int, error) {
+	query := make(url.Values)
+	query.Add("scope", "all")
+	var response statisticsResponse
+	if _, err := client.get(ctx, client.endpoint(project, "/issues_statistics", query), &response); err != nil {
+		return -1, err
+	}
+	return response.Statistics.Counts.All, nil
+}
+
+// ticket fetches every note and state event of an issue and converts everything into a Jira issue.
+func (client *Client) ticket(ctx context.Context, project string, issue Issue) (jira.JiraIssue, error) {
+	issuePath := "/issues/" + strconv.Itoa(issue.IID)
+	query := make(url.Values)
+	query.Add("per_page", strconv.Itoa(perPage))
+	query.Add("sort", "asc")
+
+	var notes []Note
+	for page := 1; page != 0; {
+		query.Set("page", strconv.Itoa(page))
+		var chunk []Note
+		var err error
+		page, err = client.get(ctx, client.endpoint(project, issuePath+"/notes", query), &chunk)
+		if err != nil {
+			return jira.JiraIssue{}, err
+		}
+		notes = append(notes, chunk...)
+	}
+
+	var events []StateEvent
+	for page := 1; page != 0; {
+		query.Set("page", strconv.Itoa(page))
+		var chunk []StateEvent
+		var err error
+		page, err = client.get(ctx, client.endpoint(project, issuePath+"/resource_state_events", query), &chunk)
+		if err != nil {
+			return jira.JiraIssue{}, err
+		}
+		events = append(events, chunk...)
+	}
+
+	return client.convert(project, issue, notes, events), nil
+}
+
+// convert maps a GitLab issue along with its notes and state events into a Jira issue. Instances
+// predating resource state events only record state changes as system notes, which are used instead.
+func (client *Client) convert(project string, issue Issue, notes []Note, events []StateEvent) jira.JiraIssue {
+	converted := jira.JiraIssue{
+		Key:  fmt.Sprintf("%s#%d", project, issue.IID),
+		ID:   strconv.Itoa(issue.ID),
+		Self: issue.WebURL,
+		Fields: jira.Fields{
+			Summary:      issue.Title,
+			Description:  issue.Description,
+			TimeEstimate: issue.TimeStats.TimeEstimate,
+			TimeSpent:    issue.TimeStats.TotalTimeSpent,
+			Created:      jira.Time(issue.CreatedAt),
+			Status:       jira.Status{Name: statusName(issue.State)},
+			Priority:     jira.PriorityFromLabels(issue.Labels, client.PriorityLabels),
+			Type:         jira.Type{Name: issueType(issue)},
+			Labels:       issue.Labels,
+		},
+	}
+
+	if issue.DueDate != "" {
+		if due, err := time.Parse("2006-01-02", issue.DueDate); err == nil {
+			converted.Fields.DueDate = jira.Time(due)
+		}
+	}
+
+	useNotes := len(events) == 0
+	for _, n := range notes {
+		if n.System {
+			if useNotes && (n.Body == "closed" || n.Body == "reopened") {
+				events = append(events, StateEvent{ID: n.ID, User: n.Author, State: n.Body, CreatedAt: n.CreatedAt})
+			}
+			continue
+		}
+		converted.Fields.Comments.Comments = append(converted.Fields.Comments.Comments, jira.Comment{
+			ID:      strconv.Itoa(n.ID),
+			Body:    n.Body,
+			Author:  author(n.Author),
+			Created: jira.Time(n.CreatedAt),
+			Updated: jira.Time(n.UpdatedAt),
+		})
+	}
+
+	for _, e := range events {
+		var item jira.ChangelogHistoryItem
+		switch e.State {
+		case "closed":
+			item = jira.ChangelogHistoryItem{Field: "status", FromString: "Open", ToString: "Closed"}
+		case "reopened":
+			item = jira.ChangelogHistoryItem{Field: "status", FromString: "Closed", ToString: "Open"}
+		default:
+			continue
+		}
+		item.FieldType = "gitlab"
+		converted.Changelog.Histories = append(converted.Changelog.Histories, jira.ChangelogHistory{
+			ID:      strconv.Itoa(e.ID),
+			Author:  author(e.User),
+			Created: jira.Time(e.CreatedAt),
+			Items:   []jira.ChangelogHistoryItem{item},
+		})
+	}
+	converted.Changelog.MaxResults = len(converted.Changelog.Histories)
+	converted.Changelog.Total = len(converted.Changelog.Histories)
+
+	return converted
+}
+
+// statusName maps GitLab issue states onto Jira status names.
+func statusName(state string) string {
+	if state == "closed" {
+		return "Closed"
+	}
+	return "Open"
+}
+
+// issueType returns "Bug" for incidents or issues labelled as bugs and "Issue" otherwise.
+func issueType(issue Issue) string {
+	if issue.IssueType == "incident" {
+		return "Bug"
+	}
+	for _, l := range issue.Labels {
+		if strings.Contains(strings.ToLower(l), "bug") {
+			return "Bug"
+		}
+	}
+	return "Issue"
+}
+
+// author builds a Jira author from a GitLab user.
+func author(u User) jira.Author {
+	return jira.Author{
+		Name:        u.Username,
+		DisplayName: u.Name,
+	}
+}
@@ -0,0 +1,47 @@
+package gitlab
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConvertStateNotes(t *testing.T) {
+	u, _ := url.Parse("https://gitlab.example.com")
+	client, err := NewClient(u)
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	created := time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC)
+	issue := Issue{ID: 100, IID: 1, Title: "Crash on start", State: "closed", CreatedAt: created}
+	notes := []Note{
+		{ID: 1, Body: "closed", System: true, CreatedAt: created.Add(24 * time.Hour)},
+		{ID: 2, Body: "Still crashing on 1.2.", CreatedAt: created.Add(48 * time.Hour)},
+		{ID: 3, Body: "reopened", System: true, CreatedAt: created.Add(48 * time.Hour)},
+		{ID: 4, Body: "changed the description", System: true, CreatedAt: created.Add(50 * time.Hour)},
+		{ID: 5, Body: "closed", System: true, CreatedAt: created.Add(72 * time.Hour)},
+	}
+
+	converted := client.convert("group/project", issue, notes, nil)
+
+	var statuses []string
+	for _, history := range converted.Changelog.Histories {
+		statuses = append(statuses, history.Items[0].ToString)
+	}
+	if want := []string{"Closed", "Open", "Closed"}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("got status changes %v, want %v", statuses, want)
+	}
+	if converted.Changelog.Total != 3 {
+		t.Errorf("got changelog total %d, want 3", converted.Changelog.Total)
+	}
+	if got := len(converted.Fields.Comments.Comments); got != 1 {
+		t.Errorf("got %d comments, want 1", got)
+	}
+
+	events := []StateEvent{{ID: 10, State: "closed", CreatedAt: created.Add(time.Hour)}}
+	converted = client.convert("group/project", issue, notes, events)
+	if got := len(converted.Changelog.Histories); got != 1 {
+		t.Errorf("got %d status changes with state events, want only the 1 event", got)
+	}
+}
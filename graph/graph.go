@@ -0,0 +1,134 @@
+package graph
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/nclandrei/ticketguru/jira"
+)
+
+const (
+	// SubtaskLink names the relation between a ticket and its subtasks.
+	SubtaskLink = "Subtask"
+	// ParentLink names the relation between a ticket and its parent (e.g. its epic on Jira Cloud).
+	ParentLink = "Parent"
+)
+
+// Graph defines an undirected graph of tickets, where edges are labelled with the types of the links
+// relating two tickets (e.g. Duplicate, Blocks, Cloners).
+type Graph struct {
+	edges map[string]map[string][]string
+}
+
+// New returns the graph formed by the links, subtasks and parents of a variadic number of tickets.
+// Linked tickets which are not stored are part of the graph too.
+func New(tickets ...jira.JiraIssue) *Graph {
+	g := &Graph{
+		edges: make(map[string]map[string][]string),
+	}
+	for _, t := range tickets {
+		g.addNode(t.Key)
+		for _, link := range t.Fields.IssueLinks {
+			if link.InwardIssue != nil {
+				g.addEdge(t.Key, link.InwardIssue.Key, link.Type.Name)
+			}
+			if link.OutwardIssue != nil {
+				g.addEdge(t.Key, link.OutwardIssue.Key, link.Type.Name)
+			}
+		}
+		for _, subtask := range t.Fields.Subtasks {
+			g.addEdge(t.Key, subtask.Key, SubtaskLink)
+		}
+		if t.Fields.Parent != nil {
+			g.addEdge(t.Key, t.Fields.Parent.Key, ParentLink)
+		}
+	}
+	return g
+}
+
+// addNode adds a ticket without any edges to the graph.
+func (g *Graph) addNode(key string) {
+	if _, ok := g.edges[key]; !ok {
+		g.edges[key] = make(map[string][]string)
+	}
+}
+
+// addEdge adds an edge between two tickets, unless one with the same link type already exists, as
+// links are reported by both of the tickets they relate.
+func (g *Graph) addEdge(from, to, linkType string) {
+	if from == "" || to == "" {
+		return
+	}
+	g.addNode(from)
+	g.addNode(to)
+	for _, t := range g.edges[from][to] {
+		if t == linkType {
+			return
+		}
+	}
+	g.edges[from][to] = append(g.edges[from][to], linkType)
+	g.edges[to][from] = append(g.edges[to][from], linkType)
+}
+
+// Neighbours returns the keys of the tickets related to a ticket through any of the link types, or through
+// any link at all if no type is given.
+func (g *Graph) Neighbours(key string, linkTypes ...string) []string {
+	var neighbours []string
+	for neighbour, types := range g.edges[key] {
+		if matches(types, linkTypes) {
+			neighbours = append(neighbours, neighbour)
+		}
+	}
+	sort.Strings(neighbours)
+	return neighbours
+}
+
+// ConnectedComponents returns the groups of at least two tickets connected through any of the link types
+// (e.g. all the duplicates of the same problem), or through any link at all if no type is given.
+// Components are sorted by decreasing size and hold sorted keys.
+func (g *Graph) ConnectedComponents(linkTypes ...string) [][]string {
+	visited := make(map[string]bool)
+	var components [][]string
+	for key := range g.edges {
+		if visited[key] {
+			continue
+		}
+		visited[key] = true
+		component := []string{key}
+		for queue := []string{key}; len(queue) > 0; queue = queue[1:] {
+			for _, neighbour := range g.Neighbours(queue[0], linkTypes...) {
+				if !visited[neighbour] {
+					visited[neighbour] = true
+					component = append(component, neighbour)
+					queue = append(queue, neighbour)
+				}
+			}
+		}
+		if len(component) > 1 {
+			sort.Strings(component)
+			components = append(components, component)
+		}
+	}
+	sort.Slice(components, func(i, j int) bool {
+		if len(components[i]) != len(components[j]) {
+			return len(components[i]) > len(components[j])
+		}
+		return components[i][0] < components[j][0]
+	})
+	return components
+}
+
+// matches returns whether any of the types of an edge is one of the wanted link types.
+func matches(types, wanted []string) bool {
+	if len(wanted) == 0 {
+		return true
+	}
+	for _, t := range types {
+		for _, w := range wanted {
+			if strings.EqualFold(t, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
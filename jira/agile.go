@@ -0,0 +1,145 @@
+package jira
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// agilePageSize defines how many boards, sprints or issues are requested per page of the Agile REST API.
+const agilePageSize = 50
+
+// Board defines a Jira Software board, as returned by the Agile REST API.
+type Board struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// boardsPage defines a page of boards returned by the Agile REST API.
+type boardsPage struct {
+	StartAt    int     `json:"startAt"`
+	MaxResults int     `json:"maxResults"`
+	IsLast     bool    `json:"isLast"`
+	Values     []Board `json:"values"`
+}
+
+// sprintsPage defines a page of sprints returned by the Agile REST API.
+type sprintsPage struct {
+	StartAt    int      `json:"startAt"`
+	MaxResults int      `json:"maxResults"`
+	IsLast     bool     `json:"isLast"`
+	Values     []Sprint `json:"values"`
+}
+
+// agilePath returns the path of a resource of the Agile REST API (e.g. /board).
+func (client *Client) agilePath(resource string) string {
+	return client.restPath("/agile/1.0" + resource)
+}
+
+// Boards returns every scrum board of a project; kanban boards are skipped as they have no sprints.
+func (client *Client) Boards(ctx context.Context, project string) ([]Board, error) {
+	var boards []Board
+	for {
+		query := make(url.Values)
+		query.Add("projectKeyOrId", project)
+		query.Add("type", "scrum")
+		query.Add("startAt", strconv.Itoa(len(boards)))
+		query.Add("maxResults", strconv.Itoa(agilePageSize))
+		var page boardsPage
+		if err := client.getJSON(ctx, client.agilePath("/board"), query, &page); err != nil {
+			return nil, err
+		}
+		boards = append(boards, page.Values...)
+		if page.IsLast || len(page.Values) == 0 {
+			return boards, nil
+		}
+	}
+}
+
+// BoardSprints returns every sprint of a board, whatever its state.
+func (client *Client) BoardSprints(ctx context.Context, boardID int) ([]Sprint, error) {
+	var sprints []Sprint
+	for {
+		query := make(url.Values)
+		query.Add("startAt", strconv.Itoa(len(sprints)))
+		query.Add("maxResults", strconv.Itoa(agilePageSize))
+		var page sprintsPage
+		path := client.agilePath("/board/" + strconv.Itoa(boardID) + "/sprint")
+		if err := client.getJSON(ctx, path, query, &page); err != nil {
+			return nil, err
+		}
+		sprints = append(sprints, page.Values...)
+		if page.IsLast || len(page.Values) == 0 {
+			return sprints, nil
+		}
+	}
+}
+
+// SprintIssueKeys returns the keys of every issue of a sprint. For closed sprints these are the issues
+// the sprint held when it was completed, including the unfinished ones moved to a later sprint.
+func (client *Client) SprintIssueKeys(ctx context.Context, sprintID int) ([]string, error) {
+	var keys []string
+	for {
+		query := make(url.Values)
+		query.Add("startAt", strconv.Itoa(len(keys)))
+		query.Add("maxResults", strconv.Itoa(agilePageSize))
+		query.Add("fields", "key")
+		var page SearchResponse
+		path := client.agilePath("/sprint/" + strconv.Itoa(sprintID) + "/issue")
+		if err := client.getJSON(ctx, path, query, &page); err != nil {
+			return nil, err
+		}
+		for _, issue := range page.Issues {
+			keys = append(keys, issue.Key)
+		}
+		if len(page.Issues) == 0 || len(keys) >= page.Total {
+			return keys, nil
+		}
+	}
+}
+
+// IssueSprints returns the sprints every issue of a project has been in, keyed by issue key and ordered by
+// start date, future sprints last. Sprints shared between several boards of the project are only counted once.
+func (client *Client) IssueSprints(ctx context.Context, project string) (map[string][]Sprint, error) {
+	boards, err := client.Boards(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int]bool)
+	issueSprints := make(map[string][]Sprint)
+	for _, board := range boards {
+		sprints, err := client.BoardSprints(ctx, board.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, sprint := range sprints {
+			if seen[sprint.ID] {
+				continue
+			}
+			seen[sprint.ID] = true
+			keys, err := client.SprintIssueKeys(ctx, sprint.ID)
+			if err != nil {
+				return nil, err
+			}
+			for _, key := range keys {
+				issueSprints[key] = append(issueSprints[key], sprint)
+			}
+		}
+	}
+
+	for key := range issueSprints {
+		sprints := issueSprints[key]
+		sort.Slice(sprints, func(i, j int) bool {
+			si, sj := time.Time(sprints[i].StartDate), time.Time(sprints[j].StartDate)
+			if si.IsZero() || sj.IsZero() {
+				return !si.IsZero() || (sj.IsZero() && sprints[i].ID < sprints[j].ID)
+			}
+			return si.Before(sj)
+		})
+	}
+	return issueSprints, nil
+}
@@ -0,0 +1,263 @@
+package jira
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Authenticator defines a way of authenticating a Jira client with a Jira instance.
+type Authenticator interface {
+	Authenticate(context.Context, *Client) error
+}
+
+// SessionAuthenticator authenticates through a cookie based session created with a username and password.
+type SessionAuthenticator struct {
+	Username string
+	Password string
+}
+
+// BasicAuthenticator authenticates every request with basic auth, using an email and an API token
+// as required by Jira Cloud.
+type BasicAuthenticator struct {
+	Email    string
+	APIToken string
+}
+
+// BearerAuthenticator authenticates every request with a personal access token, as supported by Jira Data Center.
+type BearerAuthenticator struct {
+	Token string
+}
+
+// OAuthAuthenticator authenticates every request with an OAuth 1.0a RSA-SHA1 signature, using the consumer
+// key and private key of a Jira application link and an access token obtained through the OAuth dance.
+type OAuthAuthenticator struct {
+	ConsumerKey string
+	PrivateKey  *rsa.PrivateKey
+	AccessToken string
+}
+
+// authTransport decorates the requests to the Jira host going through the base transport, so that credentials
+// are never sent to other hosts, such as the CDN Jira Cloud redirects attachment downloads to.
+type authTransport struct {
+	Base     http.RoundTripper
+	Host     string
+	decorate func(*http.Request) error
+}
+
+// RoundTrip decorates a copy of the request if it is sent to the Jira host and passes it on to the base transport.
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != t.Host {
+		return t.Base.RoundTrip(req)
+	}
+	authReq := req.Clone(req.Context())
+	if err := t.decorate(authReq); err != nil {
+		return nil, err
+	}
+	return t.Base.RoundTrip(authReq)
+}
+
+// WithAuthenticator sets the authenticator used by AuthenticateClient.
+func WithAuthenticator(authenticator Authenticator) ClientOption {
+	return func(client *Client) (*Client, error) {
+		client.Authenticator = authenticator
+		return client, nil
+	}
+}
+
+// Authenticate creates a session on the Jira instance and stores its cookies inside the client's jar.
+func (a *SessionAuthenticator) Authenticate(ctx context.Context, client *Client) error {
+	authenticationRequest := struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}{
+		a.Username,
+		a.Password,
+	}
+
+	jsonPayload, err := json.Marshal(authenticationRequest)
+	if err != nil {
+		return err
+	}
+
+	sessionURL := client.endpoint(client.restPath("/auth/1/session"), nil)
+	request, err := http.NewRequestWithContext(ctx, "POST", sessionURL, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Add("Content-Type", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if err := authenticationError(response); err != nil {
+		return err
+	}
+
+	sessionPath, err := url.Parse(sessionURL)
+	if err != nil {
+		return err
+	}
+	client.Jar.SetCookies(sessionPath, response.Cookies())
+
+	return nil
+}
+
+// Authenticate sets up basic auth on every request and checks the credentials are accepted.
+func (a *BasicAuthenticator) Authenticate(ctx context.Context, client *Client) error {
+	client.decorateRequests(func(req *http.Request) error {
+		req.SetBasicAuth(a.Email, a.APIToken)
+		return nil
+	})
+	return client.verifyCredentials(ctx)
+}
+
+// Authenticate sets up the bearer token on every request and checks it is accepted.
+func (a *BearerAuthenticator) Authenticate(ctx context.Context, client *Client) error {
+	client.decorateRequests(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+		return nil
+	})
+	return client.verifyCredentials(ctx)
+}
+
+// Authenticate sets up OAuth 1.0a signing on every request and checks the access token is accepted.
+func (a *OAuthAuthenticator) Authenticate(ctx context.Context, client *Client) error {
+	if a.PrivateKey == nil {
+		return fmt.Errorf("no private key provided for OAuth authentication")
+	}
+	client.decorateRequests(a.sign)
+	return client.verifyCredentials(ctx)
+}
+
+// sign adds an OAuth 1.0a Authorization header signed with RSA-SHA1 to a request.
+func (a *OAuthAuthenticator) sign(req *http.Request) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	oauthParams := map[string]string{
+		"oauth_consumer_key":     a.ConsumerKey,
+		"oauth_nonce":            hex.EncodeToString(nonce),
+		"oauth_signature_method": "RSA-SHA1",
+		"oauth_timestamp":        strconv.FormatInt(time.Now().Unix(), 10),
+		"oauth_token":            a.AccessToken,
+		"oauth_version":          "1.0",
+	}
+
+	var params []string
+	for k, v := range oauthParams {
+		params = append(params, percentEncode(k)+"="+percentEncode(v))
+	}
+	for k, vs := range req.URL.Query() {
+		for _, v := range vs {
+			params = append(params, percentEncode(k)+"="+percentEncode(v))
+		}
+	}
+	sort.Strings(params)
+
+	baseURL := strings.ToLower(req.URL.Scheme) + "://" + strings.ToLower(req.URL.Host) + req.URL.EscapedPath()
+	baseString := strings.ToUpper(req.Method) + "&" + percentEncode(baseURL) + "&" +
+		percentEncode(strings.Join(params, "&"))
+
+	hashed := sha1.Sum([]byte(baseString))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.PrivateKey, crypto.SHA1, hashed[:])
+	if err != nil {
+		return err
+	}
+	oauthParams["oauth_signature"] = base64.StdEncoding.EncodeToString(signature)
+
+	var header []string
+	for k, v := range oauthParams {
+		header = append(header, fmt.Sprintf(`%s="%s"`, k, percentEncode(v)))
+	}
+	sort.Strings(header)
+	req.Header.Set("Authorization", "OAuth "+strings.Join(header, ", "))
+	return nil
+}
+
+// ParseRSAPrivateKey parses a PEM encoded PKCS#1 or PKCS#8 RSA private key.
+func ParseRSAPrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in private key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse private key: %v", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// decorateRequests installs a request decorator beneath the retry transport, so that every attempt to the
+// Jira host is decorated (and, for OAuth, signed) afresh.
+func (client *Client) decorateRequests(decorate func(*http.Request) error) {
+	if retry, ok := client.Transport.(*RetryTransport); ok {
+		retry.Base = &authTransport{Base: retry.Base, Host: client.URL.Host, decorate: decorate}
+		return
+	}
+	client.Transport = &authTransport{Base: client.Transport, Host: client.URL.Host, decorate: decorate}
+}
+
+// verifyCredentials checks the credentials of the client are accepted by fetching the current user.
+func (client *Client) verifyCredentials(ctx context.Context) error {
+	resp, err := client.get(ctx, client.endpoint(client.apiPath("/myself"), nil))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return authenticationError(resp)
+}
+
+// authenticationError returns an error describing a failed authentication response, or nil on success.
+func authenticationError(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("authentication rejected with status %v: %s", resp.Status, strings.TrimSpace(string(body)))
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("authentication failed with status %v", resp.Status)
+	default:
+		return nil
+	}
+}
+
+// percentEncode encodes a string as required by OAuth 1.0a, escaping everything but unreserved characters.
+func percentEncode(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package jira_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/nclandrei/ticketguru/jira"
+)
+
+func TestCredentialsNotSentOnRedirect(t *testing.T) {
+	var cdnAuthorization []string
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cdnAuthorization = append(cdnAuthorization, r.Header.Get("Authorization"))
+		w.Write([]byte("attachment body"))
+	}))
+	defer cdn.Close()
+
+	var jiraAuthorization []string
+	jiraServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jiraAuthorization = append(jiraAuthorization, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/rest/api/2/myself":
+			w.Write([]byte(`{"name":"jira"}`))
+		case "/secure/attachment/1/trace.txt":
+			http.Redirect(w, r, cdn.URL+"/file/1", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer jiraServer.Close()
+
+	for _, tc := range []struct {
+		name          string
+		authenticator jira.Authenticator
+	}{
+		{"basic", &jira.BasicAuthenticator{Email: "jira", APIToken: "secret"}},
+		{"bearer", &jira.BearerAuthenticator{Token: "token"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cdnAuthorization, jiraAuthorization = nil, nil
+			u, _ := url.Parse(jiraServer.URL)
+			client, err := jira.NewClient(u, jira.WithAuthenticator(tc.authenticator))
+			if err != nil {
+				t.Fatalf("could not create client: %v", err)
+			}
+			if err := client.AuthenticateClient(context.Background()); err != nil {
+				t.Fatalf("could not authenticate: %v", err)
+			}
+
+			req, err := http.NewRequest("GET", jiraServer.URL+"/secure/attachment/1/trace.txt", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("could not download attachment: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("got status %v downloading the attachment, want 200", resp.Status)
+			}
+
+			if len(jiraAuthorization) != 2 || jiraAuthorization[1] == "" {
+				t.Errorf("got Authorization headers %q on Jira, want both requests authenticated", jiraAuthorization)
+			}
+			if len(cdnAuthorization) != 1 || cdnAuthorization[0] != "" {
+				t.Errorf("got Authorization headers %q on the redirect target, want none", cdnAuthorization)
+			}
+		})
+	}
+}
@@ -1,7 +1,7 @@
 package jira
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -11,15 +11,31 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+// keysPageSize defines how many issue keys are requested per page when listing keys.
+const keysPageSize = 1000
+
+// syncOverlap defines how long before the last sync incremental queries start. Jira reads JQL dates in the
+// timezone of the authenticated user's profile rather than the one of the machine running the sync, and two
+// timezones are at most 26 hours apart, so the overlap keeps updates made within the offset from being missed.
+const syncOverlap = 26 * time.Hour
+
 // Client defines the client for Jira
 type Client struct {
 	*http.Client
-	URL  *url.URL
-	lock sync.RWMutex
+	URL           *url.URL
+	Authenticator Authenticator
+	// ContextPath is the path Jira is mounted at (e.g. /jira), empty when mounted at root.
+	ContextPath string
+	// APIVersion is the version of the REST API to query (2 or 3).
+	APIVersion string
+	// CustomFields maps logical names onto the IDs of the extra fields requested for every issue.
+	CustomFields map[string]string
+	lock         sync.RWMutex
 }
 
 // SearchResponse defines the response payload retrieved through the search endpoint
@@ -51,8 +67,47 @@ type Session struct {
 // ClientOption defines an optional function to be applied on a Jira client.
 type ClientOption func(*Client) (*Client, error)
 
-// NewClient returns a new Jira Client.
-func NewClient(url *url.URL) (*Client, error) {
+// requestTimeout defines the timeout of a single attempt of a request.
+const requestTimeout = time.Minute * 3
+
+// WithRetries makes the client retry throttled or failed requests up to maxAttempts times in total.
+func WithRetries(maxAttempts int) ClientOption {
+	return func(client *Client) (*Client, error) {
+		if maxAttempts < 1 {
+			return nil, fmt.Errorf("maximum number of attempts must be at least 1, got %d", maxAttempts)
+		}
+		base := client.Transport
+		if retry, ok := base.(*RetryTransport); ok {
+			base = retry.Base
+		}
+		client.Transport = NewRetryTransport(base, maxAttempts)
+		client.Timeout = requestTimeout*time.Duration(maxAttempts) + defaultMaxDelay*time.Duration(maxAttempts-1)
+		return client, nil
+	}
+}
+
+// WithContextPath sets the path Jira is mounted at, e.g. /jira; use an empty path for Jira mounted at root.
+func WithContextPath(contextPath string) ClientOption {
+	return func(client *Client) (*Client, error) {
+		client.ContextPath = strings.TrimSuffix(contextPath, "/")
+		return client, nil
+	}
+}
+
+// WithAPIVersion sets the version of the REST API queried by the client.
+func WithAPIVersion(version string) ClientOption {
+	return func(client *Client) (*Client, error) {
+		if version != "2" && version != "3" {
+			return nil, fmt.Errorf("unsupported Jira REST API version %s", version)
+		}
+		client.APIVersion = version
+		return client, nil
+	}
+}
+
+// NewClient returns a new Jira Client; by default requests are retried up to DefaultMaxAttempts times,
+// the context path is taken from the path of the URL and version 2 of the REST API is used.
+func NewClient(url *url.URL, options ...ClientOption) (*Client, error) {
 	cookieJar, err := cookiejar.New(nil)
 	if err != nil {
 		return nil, err
@@ -66,72 +121,130 @@ func NewClient(url *url.URL) (*Client, error) {
 		TLSHandshakeTimeout: 60 * time.Second,
 	}
 
-	return &Client{
+	client := &Client{
 		Client: &http.Client{
-			Timeout:   time.Minute * 3,
+			Timeout:   requestTimeout,
 			Jar:       cookieJar,
 			Transport: transport,
 		},
-		URL: url,
-	}, nil
+		URL:         url,
+		ContextPath: strings.TrimSuffix(url.Path, "/"),
+		APIVersion:  "2",
+	}
+
+	options = append([]ClientOption{WithRetries(DefaultMaxAttempts)}, options...)
+	for _, option := range options {
+		client, err = option(client)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return client, nil
 }
 
-// setSearchPath sets the URL path for JQL search on a Jira client.
-func (client *Client) setSearchPath(projectName string, paginationIndex, pageCount int) {
-	client.lock.Lock()
-	client.URL.Path = "/jira/rest/api/2/search"
+// ProjectQuery returns the JQL expression selecting every issue of a Jira project.
+func ProjectQuery(projectName string) string {
+	return fmt.Sprintf("project=%s", projectName)
+}
+
+// UpdatedSinceQuery restricts a JQL expression to the issues updated since the given time, starting syncOverlap
+// earlier as the timezone Jira reads the date in is unknown; issues updated within the overlap are fetched again,
+// which is harmless as they are stored again unchanged.
+func UpdatedSinceQuery(jql string, since time.Time) string {
+	return fmt.Sprintf("(%s) AND updated >= \"%s\"", jql, since.Add(-syncOverlap).Local().Format(JQLTimeFormat))
+}
+
+// setSearchPath sets the URL path for JQL search on a Jira client and returns the resulting URL.
+func (client *Client) setSearchPath(jql string, paginationIndex, pageCount int) string {
 	queryValues := make(url.Values)
-	queryValues.Add("jql", fmt.Sprintf("project=%s", projectName))
+	queryValues.Add("jql", jql)
 	queryValues.Add("startAt", strconv.Itoa(paginationIndex*pageCount))
 	queryValues.Add("maxResults", strconv.Itoa(pageCount))
-	queryValues.Add("fields", "summary, created, description, attachment, comment, key, issuetype, timespent, priority, timeestimate, status, duedate, progress")
+	queryValues.Add("fields", client.requestedFields())
 	queryValues.Add("expand", "changelog")
-	client.URL.RawQuery = queryValues.Encode()
-	client.lock.Unlock()
+	return client.endpoint(client.apiPath("/search"), queryValues)
 }
 
-// AuthenticateClient authenticates a Jira client with a specific instance of Jira.
-func (client *Client) AuthenticateClient() error {
-	authenticationRequest := struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
-	}{
-		os.Getenv("JIRA_USERNAME"),
-		os.Getenv("JIRA_PASSWORD"),
-	}
-
-	client.URL.Path = "/jira/rest/auth/1/session"
-
-	jsonPayload, err := json.Marshal(authenticationRequest)
-	if err != nil {
-		return err
+// AuthenticateClient authenticates a Jira client with a specific instance of Jira, using a cookie based
+// session with JIRA_USERNAME and JIRA_PASSWORD unless another authenticator has been configured.
+func (client *Client) AuthenticateClient(ctx context.Context) error {
+	if client.Authenticator == nil {
+		client.Authenticator = &SessionAuthenticator{
+			Username: os.Getenv("JIRA_USERNAME"),
+			Password: os.Getenv("JIRA_PASSWORD"),
+		}
 	}
+	return client.Authenticator.Authenticate(ctx, client)
+}
 
-	request, err := http.NewRequest("POST", client.URL.String(), bytes.NewBuffer(jsonPayload))
+// get performs a GET request on an absolute URL, cancelled along with ctx.
+func (client *Client) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return client.Do(req)
+}
 
-	request.Header.Add("Content-Type", "application/json")
+// endpoint returns the absolute URL for a path and query without mutating the client URL.
+func (client *Client) endpoint(path string, query url.Values) string {
+	u := *client.URL
+	u.Path = path
+	u.RawQuery = query.Encode()
+	return u.String()
+}
 
-	response, err := client.Do(request)
-	if err != nil {
-		return err
-	}
+// apiPath returns the path of a REST API resource (e.g. /search) under the configured context path and version.
+func (client *Client) apiPath(resource string) string {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+	return client.ContextPath + "/rest/api/" + client.APIVersion + resource
+}
 
-	client.Jar.SetCookies(client.URL, response.Cookies())
+// restPath returns the path of a resource under the REST root of the configured context path (e.g. /auth/1/session).
+func (client *Client) restPath(resource string) string {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+	return client.ContextPath + "/rest" + resource
+}
 
-	return nil
+// DiscoverContextPath probes the server info resource under each candidate context path and keeps the first
+// one Jira answers on; with no candidates, the root and /jira are tried.
+func (client *Client) DiscoverContextPath(ctx context.Context, candidates ...string) error {
+	if len(candidates) == 0 {
+		candidates = []string{"", "/jira"}
+	}
+	for _, candidate := range candidates {
+		candidate = strings.TrimSuffix(candidate, "/")
+		resp, err := client.get(ctx, client.endpoint(candidate+"/rest/api/2/serverInfo", nil))
+		if err != nil {
+			return err
+		}
+		var serverInfo struct {
+			BaseURL string `json:"baseUrl"`
+			Version string `json:"version"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&serverInfo)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK && err == nil && serverInfo.Version != "" {
+			client.lock.Lock()
+			client.ContextPath = candidate
+			client.lock.Unlock()
+			return nil
+		}
+	}
+	return fmt.Errorf("could not find Jira under any of the context paths %q", candidates)
 }
 
-// Tickets returns a paginated slice of tickets from Jira.
+// Tickets returns a paginated slice of tickets matching a JQL expression from Jira.
 func (client *Client) Tickets(
-	projectName string,
+	ctx context.Context,
+	jql string,
 	paginationIndex int,
 	pageCount int) ([]JiraIssue, error) {
 
-	client.setSearchPath(projectName, paginationIndex, pageCount)
-	resp, err := client.Get(client.URL.String())
+	resp, err := client.get(ctx, client.setSearchPath(jql, paginationIndex, pageCount))
 
 	if err != nil {
 		return nil, err
@@ -140,18 +253,36 @@ func (client *Client) Tickets(
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("Status code different than 200: %v", resp.Status)
 	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
 	var searchResponse SearchResponse
-	if err := json.NewDecoder(resp.Body).Decode(&searchResponse); err != nil {
+	if err := json.Unmarshal(body, &searchResponse); err != nil {
 		return nil, err
 	}
+	if len(client.CustomFields) > 0 {
+		if err := client.extractCustomFields(body, searchResponse.Issues); err != nil {
+			return nil, fmt.Errorf("could not extract custom fields: %v", err)
+		}
+	}
+	for i := range searchResponse.Issues {
+		if err := client.completeChangelog(ctx, &searchResponse.Issues[i]); err != nil {
+			return nil, fmt.Errorf("could not fetch changelog of %s: %v", searchResponse.Issues[i].Key, err)
+		}
+		if err := client.completeComments(ctx, &searchResponse.Issues[i]); err != nil {
+			return nil, fmt.Errorf("could not fetch comments of %s: %v", searchResponse.Issues[i].Key, err)
+		}
+		if err := client.completeWorklogs(ctx, &searchResponse.Issues[i]); err != nil {
+			return nil, fmt.Errorf("could not fetch worklogs of %s: %v", searchResponse.Issues[i].Key, err)
+		}
+	}
 	return searchResponse.Issues, nil
 }
 
-// TicketsCount returns the total number of issues for a Jira project
-func (client *Client) TicketsCount(project string) (int, error) {
-	client.URL.Path = "/jira/rest/api/2/search"
-	client.URL.RawQuery = "jql=project=" + project
-	resp, err := client.Get(client.URL.String())
+// TicketsCount returns the total number of issues matching a JQL expression.
+func (client *Client) TicketsCount(ctx context.Context, jql string) (int, error) {
+	resp, err := client.get(ctx, client.setSearchPath(jql, 0, 0))
 	if err != nil {
 		return -1, err
 	}
@@ -166,3 +297,36 @@ func (client *Client) TicketsCount(project string) (int, error) {
 	}
 	return searchResponse.Total, nil
 }
+
+// TicketKeys returns the keys of every issue matching a JQL expression, without fetching any other field.
+func (client *Client) TicketKeys(ctx context.Context, jql string) ([]string, error) {
+	var keys []string
+	for {
+		queryValues := make(url.Values)
+		queryValues.Add("jql", jql)
+		queryValues.Add("startAt", strconv.Itoa(len(keys)))
+		queryValues.Add("maxResults", strconv.Itoa(keysPageSize))
+		queryValues.Add("fields", "key")
+
+		resp, err := client.get(ctx, client.endpoint(client.apiPath("/search"), queryValues))
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("status %d received when listing issue keys", resp.StatusCode)
+		}
+		var searchResponse SearchResponse
+		err = json.NewDecoder(resp.Body).Decode(&searchResponse)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range searchResponse.Issues {
+			keys = append(keys, issue.Key)
+		}
+		if len(searchResponse.Issues) == 0 || len(keys) >= searchResponse.Total {
+			return keys, nil
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package jira_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nclandrei/ticketguru/jira"
+	"github.com/nclandrei/ticketguru/jiratest"
+)
+
+// fixtures is the fixture directory served by the fake Jira instance.
+const fixtures = "../jiratest/testdata"
+
+// newServer starts a fake Jira instance serving the fixtures, requiring the given credentials.
+func newServer(t *testing.T, username, password string) *jiratest.Server {
+	t.Helper()
+	server, err := jiratest.NewServer(fixtures)
+	if err != nil {
+		t.Fatalf("could not start fake Jira: %v", err)
+	}
+	t.Cleanup(server.Close)
+	server.Username = username
+	server.Password = password
+	return server
+}
+
+// newClient returns an authenticated client of a fake Jira instance.
+func newClient(t *testing.T, server *jiratest.Server, options ...jira.ClientOption) *jira.Client {
+	t.Helper()
+	client, err := server.JiraClient(options...)
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	if err := client.AuthenticateClient(context.Background()); err != nil {
+		t.Fatalf("could not authenticate client: %v", err)
+	}
+	return client
+}
+
+// keys returns the keys of issues.
+func keys(issues []jira.JiraIssue) []string {
+	result := make([]string, len(issues))
+	for i := range issues {
+		result[i] = issues[i].Key
+	}
+	return result
+}
+
+func TestTicketsPagination(t *testing.T) {
+	server := newServer(t, "jira", "secret")
+	client := newClient(t, server)
+	ctx := context.Background()
+
+	count, err := client.TicketsCount(ctx, jira.ProjectQuery("KAFKA"))
+	if err != nil {
+		t.Fatalf("could not count tickets: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("got %d tickets, want 3", count)
+	}
+
+	for _, tc := range []struct {
+		page int
+		want []string
+	}{
+		{0, []string{"KAFKA-1", "KAFKA-2"}},
+		{1, []string{"KAFKA-3"}},
+		{2, []string{}},
+	} {
+		issues, err := client.Tickets(ctx, jira.ProjectQuery("KAFKA"), tc.page, 2)
+		if err != nil {
+			t.Fatalf("could not fetch page %d: %v", tc.page, err)
+		}
+		if got := keys(issues); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("page %d: got %v, want %v", tc.page, got, tc.want)
+		}
+	}
+
+	all, err := client.TicketKeys(ctx, jira.ProjectQuery("KAFKA"))
+	if err != nil {
+		t.Fatalf("could not list keys: %v", err)
+	}
+	if want := []string{"KAFKA-1", "KAFKA-2", "KAFKA-3"}; !reflect.DeepEqual(all, want) {
+		t.Errorf("got keys %v, want %v", all, want)
+	}
+}
+
+func TestTicketsJQL(t *testing.T) {
+	server := newServer(t, "jira", "secret")
+	client := newClient(t, server)
+	since := time.Date(2019, 1, 1, 0, 0, 0, 0, time.Local)
+	// KAFKA-2 was last updated then, so syncs within a timezone offset of that time must still fetch it.
+	updated := time.Date(2019, 1, 15, 8, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		jql  string
+		want []string
+	}{
+		{"project = HADOOP AND status = Open", []string{"HADOOP-1"}},
+		{"project in (KAFKA, HADOOP) AND issuetype = Bug ORDER BY key ASC", []string{"HADOOP-1", "KAFKA-1", "KAFKA-3"}},
+		{"(project = KAFKA) AND status not in (Closed, Resolved)", []string{"KAFKA-2"}},
+		{`key in ("KAFKA-3", HADOOP-2)`, []string{"HADOOP-2", "KAFKA-3"}},
+		{jira.UpdatedSinceQuery(jira.ProjectQuery("KAFKA"), since), []string{"KAFKA-2"}},
+		{jira.UpdatedSinceQuery(jira.ProjectQuery("KAFKA"), updated.Add(12*time.Hour)), []string{"KAFKA-2"}},
+		{jira.UpdatedSinceQuery(jira.ProjectQuery("KAFKA"), updated.Add(48*time.Hour)), []string{}},
+	} {
+		issues, err := client.Tickets(context.Background(), tc.jql, 0, 50)
+		if err != nil {
+			t.Errorf("%s: could not fetch tickets: %v", tc.jql, err)
+			continue
+		}
+		if got := keys(issues); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.jql, got, tc.want)
+		}
+	}
+
+	if _, err := client.Tickets(context.Background(), "summary ~ consumer", 0, 50); err == nil {
+		t.Errorf("got no error for JQL the fake search does not understand")
+	}
+}
+
+func TestTicketsCompletesTruncatedResources(t *testing.T) {
+	server := newServer(t, "jira", "secret")
+	server.MaxEmbedded = 1
+	client := newClient(t, server)
+
+	issues, err := client.Tickets(context.Background(), "key = KAFKA-1", 0, 50)
+	if err != nil {
+		t.Fatalf("could not fetch tickets: %v", err)
+	}
+	if len(issues) != 1 {
+		t.Fatalf("got %d tickets, want 1", len(issues))
+	}
+	issue := issues[0]
+
+	if got := len(issue.Changelog.Histories); got != 3 || issue.Changelog.Total != 3 {
+		t.Errorf("got %d of %d histories, want 3 of 3", got, issue.Changelog.Total)
+	}
+	if got := issue.Changelog.Histories[2].Items[0].ToString; got != "Resolved" {
+		t.Errorf("got last status %q, want Resolved", got)
+	}
+	if got := len(issue.Fields.Comments.Comments); got != 3 || issue.Fields.Comments.Total != 3 {
+		t.Errorf("got %d of %d comments, want 3 of 3", got, issue.Fields.Comments.Total)
+	}
+	if got := issue.Fields.Comments.Comments[2].Body; got != "Patch attached, please review." {
+		t.Errorf("got last comment %q", got)
+	}
+	if got := len(issue.Fields.Worklogs.Worklogs); got != 2 || issue.Fields.Worklogs.Total != 2 {
+		t.Errorf("got %d of %d worklogs, want 2 of 2", got, issue.Fields.Worklogs.Total)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		authenticator jira.Authenticator
+		wantErr       bool
+	}{
+		{"session", &jira.SessionAuthenticator{Username: "jira", Password: "secret"}, false},
+		{"session with wrong password", &jira.SessionAuthenticator{Username: "jira", Password: "wrong"}, true},
+		{"basic", &jira.BasicAuthenticator{Email: "jira", APIToken: "secret"}, false},
+		{"basic with wrong token", &jira.BasicAuthenticator{Email: "jira", APIToken: "wrong"}, true},
+		{"bearer", &jira.BearerAuthenticator{Token: "token"}, false},
+		{"bearer with wrong token", &jira.BearerAuthenticator{Token: "wrong"}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newServer(t, "jira", "secret")
+			server.Token = "token"
+			client, err := server.JiraClient(jira.WithAuthenticator(tc.authenticator))
+			if err != nil {
+				t.Fatalf("could not create client: %v", err)
+			}
+			err = client.AuthenticateClient(context.Background())
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got no error authenticating with wrong credentials")
+				}
+				if _, err := client.TicketsCount(context.Background(), jira.ProjectQuery("KAFKA")); err == nil {
+					t.Errorf("got no error searching with wrong credentials")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("could not authenticate: %v", err)
+			}
+			count, err := client.TicketsCount(context.Background(), jira.ProjectQuery("KAFKA"))
+			if err != nil {
+				t.Fatalf("could not count tickets: %v", err)
+			}
+			if count != 3 {
+				t.Errorf("got %d tickets, want 3", count)
+			}
+		})
+	}
+}
+
+func TestIssueSprints(t *testing.T) {
+	server := newServer(t, "jira", "secret")
+	client := newClient(t, server)
+
+	issueSprints, err := client.IssueSprints(context.Background(), "KAFKA")
+	if err != nil {
+		t.Fatalf("could not fetch sprints: %v", err)
+	}
+	names := make(map[string]string)
+	for key, sprints := range issueSprints {
+		var sprintNames []string
+		for _, sprint := range sprints {
+			sprintNames = append(sprintNames, sprint.Name)
+		}
+		names[key] = strings.Join(sprintNames, ", ")
+	}
+	want := map[string]string{
+		"KAFKA-1": "Kafka Sprint 1",
+		"KAFKA-2": "Kafka Sprint 1, Kafka Sprint 2",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got sprints %v, want %v", names, want)
+	}
+}
+
+func TestTicketsCustomFields(t *testing.T) {
+	server := newServer(t, "jira", "secret")
+	client := newClient(t, server, jira.WithCustomFields(map[string]string{"labels": "labels", "severity": "priority"}))
+
+	issues, err := client.Tickets(context.Background(), jira.ProjectQuery("KAFKA"), 0, 50)
+	if err != nil {
+		t.Fatalf("could not fetch tickets: %v", err)
+	}
+	for _, issue := range issues {
+		if _, ok := issue.CustomFields["labels"]; !ok {
+			t.Errorf("%s: got no labels custom field", issue.Key)
+		}
+		if _, ok := issue.CustomFields["severity"]; !ok {
+			t.Errorf("%s: got no severity custom field", issue.Key)
+		}
+	}
+}
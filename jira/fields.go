@@ -0,0 +1,97 @@
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// defaultFields lists the fields requested for every issue, besides the mapped custom fields.
+var defaultFields = []string{
+	"summary", "created", "description", "attachment", "comment", "key", "issuetype", "timespent",
+	"priority", "timeestimate", "status", "duedate", "progress", "labels", "issuelinks", "subtasks", "parent",
+	"worklog",
+}
+
+// LoadFieldMapping reads a JSON file mapping logical names onto Jira field IDs, e.g.
+// {"storyPoints": "customfield_10002", "sprint": "customfield_10007", "components": "components"}.
+func LoadFieldMapping(path string) (map[string]string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var mapping map[string]string
+	if err := json.Unmarshal(content, &mapping); err != nil {
+		return nil, fmt.Errorf("could not parse field mapping %s: %v", path, err)
+	}
+	for name, id := range mapping {
+		if name == "" || id == "" {
+			return nil, fmt.Errorf("field mapping %s contains an empty name or ID", path)
+		}
+	}
+	return mapping, nil
+}
+
+// WithCustomFields makes the client request the fields of a mapping (logical name to field ID) and store
+// their raw values in the CustomFields of every fetched issue.
+func WithCustomFields(mapping map[string]string) ClientOption {
+	return func(client *Client) (*Client, error) {
+		client.CustomFields = mapping
+		return client, nil
+	}
+}
+
+// requestedFields returns the comma separated list of fields requested for every issue.
+func (client *Client) requestedFields() string {
+	fields := append([]string{}, defaultFields...)
+	for _, id := range client.CustomFields {
+		fields = append(fields, id)
+	}
+	return strings.Join(fields, ",")
+}
+
+// extractCustomFields copies the raw values of the mapped fields from a search response into its issues.
+func (client *Client) extractCustomFields(body []byte, issues []JiraIssue) error {
+	var raw struct {
+		Issues []json.RawMessage `json:"issues"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return err
+	}
+	if len(raw.Issues) > len(issues) {
+		return fmt.Errorf("search response holds %d issues, %d decoded", len(raw.Issues), len(issues))
+	}
+	for i := range raw.Issues {
+		if err := ExtractCustomFields(raw.Issues[i], &issues[i], client.CustomFields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExtractCustomFields copies the raw values of the fields of a mapping (logical name to field ID) from the
+// JSON of an issue into the CustomFields of its decoded issue.
+func ExtractCustomFields(raw json.RawMessage, issue *JiraIssue, mapping map[string]string) error {
+	var fields struct {
+		Key    string                     `json:"key"`
+		Fields map[string]json.RawMessage `json:"fields"`
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return err
+	}
+	if fields.Key != issue.Key {
+		return fmt.Errorf("raw issue %s does not match issue %s", fields.Key, issue.Key)
+	}
+	for name, id := range mapping {
+		value, ok := fields.Fields[id]
+		if !ok {
+			continue
+		}
+		if issue.CustomFields == nil {
+			issue.CustomFields = make(map[string]json.RawMessage)
+		}
+		issue.CustomFields[name] = value
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// subresourcePageSize defines how many entries are requested per page of an issue subresource.
+const subresourcePageSize = 100
+
+// errNotFound is returned when a resource does not exist on the Jira instance (e.g. endpoints only
+// available on Jira Cloud).
+var errNotFound = errors.New("resource not found")
+
+// ChangelogPage defines a page of the changelog of an issue, as returned by the issue changelog endpoint.
+type ChangelogPage struct {
+	StartAt    int                `json:"startAt"`
+	MaxResults int                `json:"maxResults"`
+	Total      int                `json:"total"`
+	IsLast     bool               `json:"isLast"`
+	Values     []ChangelogHistory `json:"values"`
+}
+
+// getJSON performs a GET request on a path of the Jira instance and decodes the JSON response into v.
+func (client *Client) getJSON(ctx context.Context, path string, query url.Values, v interface{}) error {
+	resp, err := client.get(ctx, client.endpoint(path, query))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code different than 200 for %s: %v", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// completeChangelog fetches the histories of an issue missing from the changelog embedded in search results.
+// The paginated changelog endpoint only exists on Jira Cloud, so Jira Server falls back on the single issue
+// endpoint, which expands the whole changelog.
+func (client *Client) completeChangelog(ctx context.Context, issue *JiraIssue) error {
+	if issue.Changelog.Total <= len(issue.Changelog.Histories) {
+		return nil
+	}
+
+	var histories []ChangelogHistory
+	for {
+		query := make(url.Values)
+		query.Add("startAt", strconv.Itoa(len(histories)))
+		query.Add("maxResults", strconv.Itoa(subresourcePageSize))
+		var page ChangelogPage
+		err := client.getJSON(ctx, client.apiPath("/issue/"+issue.Key+"/changelog"), query, &page)
+		if err == errNotFound {
+			return client.expandChangelog(ctx, issue)
+		}
+		if err != nil {
+			return err
+		}
+		histories = append(histories, page.Values...)
+		if page.IsLast || len(page.Values) == 0 || len(histories) >= page.Total {
+			break
+		}
+	}
+
+	issue.Changelog = Changelog{
+		StartAt:    0,
+		MaxResults: len(histories),
+		Total:      len(histories),
+		Histories:  histories,
+	}
+	return nil
+}
+
+// expandChangelog replaces the changelog of an issue with the one expanded by the single issue endpoint.
+func (client *Client) expandChangelog(ctx context.Context, issue *JiraIssue) error {
+	query := make(url.Values)
+	query.Add("fields", "key")
+	query.Add("expand", "changelog")
+	var expanded struct {
+		Changelog Changelog `json:"changelog"`
+	}
+	if err := client.getJSON(ctx, client.apiPath("/issue/"+issue.Key), query, &expanded); err != nil {
+		return err
+	}
+	issue.Changelog = expanded.Changelog
+	return nil
+}
+
+// completeComments fetches every comment of an issue when the comments embedded in search results are truncated.
+func (client *Client) completeComments(ctx context.Context, issue *JiraIssue) error {
+	if issue.Fields.Comments.Total <= len(issue.Fields.Comments.Comments) {
+		return nil
+	}
+
+	var comments []Comment
+	var total int
+	for {
+		query := make(url.Values)
+		query.Add("startAt", strconv.Itoa(len(comments)))
+		query.Add("maxResults", strconv.Itoa(subresourcePageSize))
+		query.Add("orderBy", "created")
+		var page Comments
+		if err := client.getJSON(ctx, client.apiPath("/issue/"+issue.Key+"/comment"), query, &page); err != nil {
+			return err
+		}
+		comments = append(comments, page.Comments...)
+		total = page.Total
+		if len(page.Comments) == 0 || len(comments) >= page.Total {
+			break
+		}
+	}
+
+	issue.Fields.Comments = Comments{
+		Comments:   comments,
+		StartAt:    0,
+		MaxResults: len(comments),
+		Total:      total,
+	}
+	return nil
+}
+
+// completeWorklogs fetches every worklog of an issue when the worklogs embedded in search results are truncated.
+func (client *Client) completeWorklogs(ctx context.Context, issue *JiraIssue) error {
+	if issue.Fields.Worklogs.Total <= len(issue.Fields.Worklogs.Worklogs) {
+		return nil
+	}
+
+	var worklogs []Worklog
+	var total int
+	for {
+		query := make(url.Values)
+		query.Add("startAt", strconv.Itoa(len(worklogs)))
+		query.Add("maxResults", strconv.Itoa(subresourcePageSize))
+		var page Worklogs
+		if err := client.getJSON(ctx, client.apiPath("/issue/"+issue.Key+"/worklog"), query, &page); err != nil {
+			return err
+		}
+		worklogs = append(worklogs, page.Worklogs...)
+		total = page.Total
+		if len(page.Worklogs) == 0 || len(worklogs) >= page.Total {
+			break
+		}
+	}
+
+	issue.Fields.Worklogs = Worklogs{
+		Worklogs:   worklogs,
+		StartAt:    0,
+		MaxResults: len(worklogs),
+		Total:      total,
+	}
+	return nil
+}
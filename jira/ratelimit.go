@@ -0,0 +1,160 @@
+package jira
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRequestsPerSecond defines the rate a RateLimiter starts from and recovers up to.
+	DefaultRequestsPerSecond = 5
+	// minRateFraction defines the lowest rate a RateLimiter backs off to, as a fraction of its maximum rate.
+	minRateFraction = 0.02
+	// recoveryFraction defines by how much the rate grows on every fast response, as a fraction of the maximum rate.
+	recoveryFraction = 0.05
+	// latencySpike defines how many times slower than average a response must be to count as a latency spike.
+	latencySpike = 3
+	// minLatencySpike defines how slow a response must be, at least, to count as a latency spike, so that the
+	// jitter of fast responses is not mistaken for one.
+	minLatencySpike = time.Second
+	// latencyWeight defines the weight of the latest response in the moving average of the latency.
+	latencyWeight = 0.1
+	// backoffInterval defines how long a backoff holds before the rate can be lowered again, so that the
+	// responses to requests already in flight do not lower it several times over.
+	backoffInterval = time.Second
+)
+
+// RateLimiter wraps an http.RoundTripper and spaces out the requests sent through it with a token bucket
+// shared by every goroutine using the transport. Its rate adapts to the server: it is halved on 429 and 503
+// responses, network errors and latency spikes (responses over a second and three times slower than average),
+// and grows back additively on every other response, up to MaxRate requests per second.
+type RateLimiter struct {
+	Base    http.RoundTripper
+	MaxRate float64
+
+	lock    sync.Mutex
+	rate    float64
+	tokens  float64
+	last    time.Time
+	latency time.Duration
+	backoff time.Time
+}
+
+// NewRateLimiter returns a new RateLimiter wrapping base and allowing at most maxRate requests per second.
+func NewRateLimiter(base http.RoundTripper, maxRate float64) *RateLimiter {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RateLimiter{
+		Base:    base,
+		MaxRate: maxRate,
+		rate:    maxRate,
+		tokens:  1,
+		last:    time.Now(),
+	}
+}
+
+// WithRateLimit makes the client send at most requestsPerSecond requests per second, backing off when the
+// server throttles or slows down. Every attempt of a retried request is rate limited.
+func WithRateLimit(requestsPerSecond float64) ClientOption {
+	return func(client *Client) (*Client, error) {
+		if requestsPerSecond <= 0 {
+			return nil, fmt.Errorf("requests per second must be positive, got %v", requestsPerSecond)
+		}
+		if retry, ok := client.Transport.(*RetryTransport); ok {
+			if limiter, ok := retry.Base.(*RateLimiter); ok {
+				retry.Base = limiter.Base
+			}
+			retry.Base = NewRateLimiter(retry.Base, requestsPerSecond)
+			return client, nil
+		}
+		client.Transport = NewRateLimiter(client.Transport, requestsPerSecond)
+		return client, nil
+	}
+}
+
+// Rate returns the number of requests per second currently allowed.
+func (l *RateLimiter) Rate() float64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.rate
+}
+
+// RoundTrip waits for a token, then executes a single HTTP transaction and adapts the rate to its outcome.
+func (l *RateLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := l.wait(req); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := l.Base.RoundTrip(req)
+	if req.Context().Err() == nil {
+		l.observe(time.Since(start), throttled(resp, err))
+	}
+	return resp, err
+}
+
+// wait blocks until a token is available or the context of the request is done.
+func (l *RateLimiter) wait(req *http.Request) error {
+	for {
+		l.lock.Lock()
+		l.refill(time.Now())
+		if l.tokens >= 1 {
+			l.tokens--
+			l.lock.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.lock.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// refill adds the tokens accrued since the last refill, holding at most a second's worth of requests.
+func (l *RateLimiter) refill(now time.Time) {
+	l.tokens = math.Min(math.Max(l.rate, 1), l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+}
+
+// observe adapts the rate to the latency of a response and whether the server throttled it.
+func (l *RateLimiter) observe(latency time.Duration, throttled bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	spike := l.latency > 0 && latency > latencySpike*l.latency && latency > minLatencySpike
+	switch {
+	case throttled || spike:
+		if now.After(l.backoff) {
+			l.refill(now)
+			l.rate = math.Max(l.rate/2, l.MaxRate*minRateFraction)
+			l.backoff = now.Add(backoffInterval)
+		}
+	case now.After(l.backoff):
+		l.refill(now)
+		l.rate = math.Min(l.rate+l.MaxRate*recoveryFraction, l.MaxRate)
+	}
+
+	if l.latency == 0 {
+		l.latency = latency
+	} else {
+		l.latency += time.Duration(latencyWeight * float64(latency-l.latency))
+	}
+}
+
+// throttled returns whether the outcome of a request signals an overloaded or throttling server.
+func throttled(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+}
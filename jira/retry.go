@@ -0,0 +1,139 @@
+package jira
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultMaxAttempts defines how many times a request is attempted before giving up.
+	DefaultMaxAttempts = 5
+	// defaultBaseDelay defines the initial backoff delay, doubled on every attempt.
+	defaultBaseDelay = time.Second
+	// defaultMaxDelay caps the exponential backoff delay.
+	defaultMaxDelay = time.Minute
+)
+
+// RetryTransport wraps an http.RoundTripper and retries requests failing with network errors or with
+// statuses signalling throttling or temporary unavailability (429, 502, 503, 504).
+type RetryTransport struct {
+	Base        http.RoundTripper
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// NewRetryTransport returns a new RetryTransport wrapping base with the default delays.
+func NewRetryTransport(base http.RoundTripper, maxAttempts int) *RetryTransport {
+	return &RetryTransport{
+		Base:        base,
+		MaxAttempts: maxAttempts,
+		BaseDelay:   defaultBaseDelay,
+		MaxDelay:    defaultMaxDelay,
+	}
+}
+
+// RoundTrip executes a single HTTP transaction, retrying it with jittered exponential backoff. Delays
+// requested by the server through Retry-After or X-RateLimit-Reset take precedence over the backoff, capped
+// at MaxDelay so that the attempts still fit in the client timeout set by WithRetries.
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+	rewindable := req.Body == nil || req.GetBody != nil
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 && req.Body != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, bodyErr
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		resp, err = t.Base.RoundTrip(attemptReq)
+		if !rewindable || !retryable(resp, err) || attempt+1 >= t.MaxAttempts {
+			return resp, err
+		}
+
+		delay := t.backoff(attempt)
+		if resp != nil {
+			if wait, ok := serverDelay(resp.Header); ok {
+				delay = wait
+				if delay > t.MaxDelay {
+					delay = t.MaxDelay
+				}
+			}
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns a random delay between zero and the exponential backoff ceiling for an attempt.
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	ceiling := t.BaseDelay << uint(attempt)
+	if ceiling <= 0 || ceiling > t.MaxDelay {
+		ceiling = t.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// retryable returns whether a request should be attempted again given its outcome.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// serverDelay returns the delay requested by the server, either through Retry-After (seconds or HTTP date)
+// or, when the rate limit is exhausted, through X-RateLimit-Reset (epoch seconds or ISO 8601 timestamp).
+func serverDelay(header http.Header) (time.Duration, bool) {
+	if retryAfter := header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if date, err := http.ParseTime(retryAfter); err == nil {
+			return positive(time.Until(date)), true
+		}
+	}
+	if header.Get("X-RateLimit-Remaining") != "0" {
+		return 0, false
+	}
+	reset := header.Get("X-RateLimit-Reset")
+	if epoch, err := strconv.ParseInt(reset, 10, 64); err == nil {
+		return positive(time.Until(time.Unix(epoch, 0))), true
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00", "2006-01-02T15:04Z"} {
+		if date, err := time.Parse(layout, reset); err == nil {
+			return positive(time.Until(date)), true
+		}
+	}
+	return 0, false
+}
+
+// positive clamps negative durations to zero.
+func positive(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
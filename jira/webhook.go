@@ -0,0 +1,186 @@
+package jira
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Webhook events handled when merging payloads into stored tickets.
+const (
+	IssueCreatedEvent   = "jira:issue_created"
+	IssueUpdatedEvent   = "jira:issue_updated"
+	CommentCreatedEvent = "comment_created"
+)
+
+// ErrIssueNotStored is returned by Merge for comment events on issues that are not stored yet, whose payloads
+// only carry a partial issue, without creation date, description or changelog.
+var ErrIssueNotStored = errors.New("issue of comment event is not stored")
+
+// WebhookEvent defines the payload Jira posts to a registered webhook.
+type WebhookEvent struct {
+	// Timestamp is the time of the event in milliseconds since the epoch.
+	Timestamp    int64      `json:"timestamp"`
+	WebhookEvent string     `json:"webhookEvent"`
+	User         Author     `json:"user"`
+	Issue        *JiraIssue `json:"issue"`
+	Changelog    *struct {
+		ID    string                 `json:"id"`
+		Items []ChangelogHistoryItem `json:"items"`
+	} `json:"changelog,omitempty"`
+	Comment *Comment `json:"comment,omitempty"`
+}
+
+// Supported returns whether the event is one Merge knows how to apply.
+func (e *WebhookEvent) Supported() bool {
+	switch e.WebhookEvent {
+	case IssueCreatedEvent, IssueUpdatedEvent, CommentCreatedEvent:
+		return true
+	default:
+		return false
+	}
+}
+
+// Merge applies the event onto the stored version of its issue, or onto the issue carried by the event if it
+// is not stored yet, and returns the resulting issue marked as stale. Webhook payloads carry neither the full
+// changelog nor, usually, the comments and worklogs, so those are kept from the stored version and extended
+// with the changelog entry or comment of the event. Derived analysis fields are kept as well, unless the
+// summary, description or comments changed, in which case the scores and text derived fields are reset.
+// Comment events on issues not stored yet return ErrIssueNotStored.
+func (e *WebhookEvent) Merge(stored *JiraIssue) (*JiraIssue, error) {
+	if e.Issue == nil || e.Issue.Key == "" {
+		return nil, fmt.Errorf("%s event carries no issue", e.WebhookEvent)
+	}
+
+	if stored == nil && e.WebhookEvent == CommentCreatedEvent {
+		return nil, ErrIssueNotStored
+	}
+
+	merged := *e.Issue
+	if stored != nil {
+		merged = *stored
+	}
+
+	switch e.WebhookEvent {
+	case IssueCreatedEvent, IssueUpdatedEvent:
+		if stored != nil {
+			merged.Fields = mergeFields(stored.Fields, e.Issue.Fields)
+		}
+		if e.Changelog != nil && len(e.Changelog.Items) > 0 && !hasHistory(merged.Changelog, e.Changelog.ID) {
+			merged.Changelog.Histories = append(merged.Changelog.Histories, ChangelogHistory{
+				ID:      e.Changelog.ID,
+				Author:  e.User,
+				Created: Time(time.Unix(0, e.Timestamp*int64(time.Millisecond))),
+				Items:   e.Changelog.Items,
+			})
+			merged.Changelog.Total++
+			merged.Changelog.MaxResults = len(merged.Changelog.Histories)
+		}
+	case CommentCreatedEvent:
+		if e.Comment == nil {
+			return nil, fmt.Errorf("%s event carries no comment", e.WebhookEvent)
+		}
+		comments := &merged.Fields.Comments
+		if !hasComment(*comments, e.Comment.ID) {
+			comments.Comments = append(comments.Comments, *e.Comment)
+			comments.MaxResults = len(comments.Comments)
+			comments.Total++
+		}
+	default:
+		return nil, fmt.Errorf("unsupported webhook event %s", e.WebhookEvent)
+	}
+
+	if stored != nil && textChanged(stored, &merged) {
+		resetTextAnalyses(&merged)
+	}
+	merged.MarkStale()
+	return &merged, nil
+}
+
+// textChanged returns whether the summary, description or comments of an issue differ from its stored version.
+func textChanged(stored, merged *JiraIssue) bool {
+	if stored.Fields.Summary != merged.Fields.Summary || stored.Fields.Description != merged.Fields.Description {
+		return true
+	}
+	storedComments, mergedComments := stored.Fields.Comments.Comments, merged.Fields.Comments.Comments
+	if len(storedComments) != len(mergedComments) {
+		return true
+	}
+	for i := range storedComments {
+		if storedComments[i].Body != mergedComments[i].Body {
+			return true
+		}
+	}
+	return false
+}
+
+// resetTextAnalyses clears the scores and derived fields computed from the text of an issue, so that they are
+// computed again from its new text.
+func resetTextAnalyses(issue *JiraIssue) {
+	issue.Sentiment = Sentiment{}
+	issue.GrammarCorrectness = GrammarCorrectness{}
+	issue.HasStackTrace = false
+	issue.HasStepsToReproduce = false
+	issue.SummaryDescWordsCount = 0
+	issue.CommentWordsCount = 0
+}
+
+// mergeFields returns the fields of an updated issue, keeping the comments and worklogs of the stored fields
+// when the update holds fewer of them, and the hashes of attachments already downloaded.
+func mergeFields(stored, updated Fields) Fields {
+	if len(updated.Comments.Comments) < len(stored.Comments.Comments) {
+		updated.Comments = stored.Comments
+	}
+	if len(updated.Worklogs.Worklogs) < len(stored.Worklogs.Worklogs) {
+		updated.Worklogs = stored.Worklogs
+	}
+	keepAttachmentHashes(stored.Attachments, updated.Attachments)
+	return updated
+}
+
+// KeepStored copies onto a fetched issue what only its stored version holds: the hashes of the attachments
+// already downloaded into the attachment store and, unless the fetch loaded them, the sprints, which searches
+// do not return.
+func (t *JiraIssue) KeepStored(stored JiraIssue) {
+	keepAttachmentHashes(stored.Fields.Attachments, t.Fields.Attachments)
+	if t.Sprints == nil {
+		t.Sprints = stored.Sprints
+	}
+}
+
+// keepAttachmentHashes copies the hashes of the stored attachments already downloaded onto the updated
+// attachments with the same IDs.
+func keepAttachmentHashes(stored, updated []Attachment) {
+	hashes := make(map[string]Attachment)
+	for _, a := range stored {
+		if a.SHA256 != "" {
+			hashes[a.ID] = a
+		}
+	}
+	for i, a := range updated {
+		if downloaded, ok := hashes[a.ID]; ok && a.SHA256 == "" {
+			updated[i].SHA256 = downloaded.SHA256
+			updated[i].ContentSize = downloaded.ContentSize
+		}
+	}
+}
+
+// hasHistory returns whether a changelog already holds the history with the given ID.
+func hasHistory(changelog Changelog, id string) bool {
+	for _, history := range changelog.Histories {
+		if id != "" && history.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// hasComment returns whether the comments already hold the comment with the given ID.
+func hasComment(comments Comments, id string) bool {
+	for _, comment := range comments.Comments {
+		if id != "" && comment.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package jiratest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nclandrei/ticketguru/jira"
+)
+
+var (
+	// listExpr matches the value lists of in and not in clauses, e.g. key in (KAFKA-1, KAFKA-2).
+	listExpr = regexp.MustCompile(`(?i)\b(not\s+in|in)\s*\(([^)]*)\)`)
+	// orderByExpr matches the ORDER BY clause ending a JQL expression, which the fake search ignores.
+	orderByExpr = regexp.MustCompile(`(?i)\s+order\s+by\s+.*$`)
+	// andExpr matches the AND operator joining the clauses of a JQL expression.
+	andExpr = regexp.MustCompile(`(?i)\s+and\s+`)
+	// orExpr matches the OR operator, which the fake search does not understand.
+	orExpr = regexp.MustCompile(`(?i)\s+or\s+`)
+	// clauseExpr matches a single clause: a field compared to a value, or to a list of values once lists
+	// have been bracketed.
+	clauseExpr = regexp.MustCompile(`(?i)^(\w+)\s*(!=|>=|<=|=|not\s+in|in)\s*(\[[^\]]*\]|"[^"]*"|'[^']*'|\S+)$`)
+)
+
+// jqlDateFormats lists the formats of the dates JQL compares the created and updated fields to.
+var jqlDateFormats = []string{jira.JQLTimeFormat, "2006-01-02 15:04", "2006/01/02", "2006-01-02"}
+
+// clause defines a single comparison of a JQL expression.
+type clause struct {
+	field    string
+	operator string
+	values   []string
+}
+
+// parseJQL parses the subset of JQL the fake search understands: clauses joined by AND, comparing the project,
+// key, status, issue type or priority of issues to a value with =, != and in, or their created and updated
+// dates with >= and <=. Grouping parentheses are ignored and so is ORDER BY, issues being returned by key.
+func parseJQL(jql string) ([]clause, error) {
+	jql = orderByExpr.ReplaceAllString(strings.TrimSpace(jql), "")
+	jql = listExpr.ReplaceAllString(jql, "$1 [$2]")
+	jql = strings.NewReplacer("(", " ", ")", " ").Replace(jql)
+	jql = strings.TrimSpace(jql)
+	if jql == "" {
+		return nil, nil
+	}
+	if orExpr.MatchString(jql) {
+		return nil, fmt.Errorf("OR is not supported by the fake search")
+	}
+
+	var clauses []clause
+	for _, part := range andExpr.Split(jql, -1) {
+		match := clauseExpr.FindStringSubmatch(strings.TrimSpace(part))
+		if match == nil {
+			return nil, fmt.Errorf("could not parse JQL clause %q", part)
+		}
+		c := clause{
+			field:    strings.ToLower(match[1]),
+			operator: strings.ToLower(strings.Join(strings.Fields(match[2]), " ")),
+		}
+		if strings.HasPrefix(match[3], "[") {
+			for _, value := range strings.Split(strings.Trim(match[3], "[]"), ",") {
+				c.values = append(c.values, unquote(value))
+			}
+		} else {
+			c.values = []string{unquote(match[3])}
+		}
+		if err := c.validate(); err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, c)
+	}
+	return clauses, nil
+}
+
+// validate checks the fake search can evaluate a clause.
+func (c clause) validate() error {
+	switch c.field {
+	case "project", "key", "issuekey", "status", "issuetype", "type", "priority":
+		if c.operator == ">=" || c.operator == "<=" {
+			return fmt.Errorf("operator %s is not supported for field %s", c.operator, c.field)
+		}
+	case "created", "updated":
+		if c.operator != ">=" && c.operator != "<=" {
+			return fmt.Errorf("operator %s is not supported for field %s", c.operator, c.field)
+		}
+		if _, err := parseJQLDate(c.values[0]); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("field %s is not supported by the fake search", c.field)
+	}
+	return nil
+}
+
+// matches returns whether an issue satisfies a clause.
+func (c clause) matches(i issue) bool {
+	fields, _ := i.json["fields"].(map[string]interface{})
+	switch c.field {
+	case "created", "updated":
+		value, _ := fields[c.field].(string)
+		issueTime, err := time.Parse("2006-01-02T15:04:05.000-0700", value)
+		if err != nil {
+			return false
+		}
+		date, _ := parseJQLDate(c.values[0])
+		if c.operator == ">=" {
+			return !issueTime.Before(date)
+		}
+		return !issueTime.After(date)
+	}
+
+	var candidates []string
+	switch c.field {
+	case "project":
+		candidates = append(candidates, jira.ProjectKey(i.key), name(fields["project"], "key"),
+			name(fields["project"], "name"))
+	case "key", "issuekey":
+		candidates = append(candidates, i.key)
+	case "issuetype", "type":
+		candidates = append(candidates, name(fields["issuetype"], "name"))
+	default:
+		candidates = append(candidates, name(fields[c.field], "name"))
+	}
+	found := false
+	for _, value := range c.values {
+		for _, candidate := range candidates {
+			if candidate != "" && strings.EqualFold(candidate, value) {
+				found = true
+			}
+		}
+	}
+	if c.operator == "!=" || c.operator == "not in" {
+		return !found
+	}
+	return found
+}
+
+// parseJQLDate parses a date as written in JQL, in the local timezone like Jira does for the current user.
+func parseJQLDate(value string) (time.Time, error) {
+	for _, format := range jqlDateFormats {
+		if date, err := time.ParseInLocation(format, value, time.Local); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("could not parse JQL date %q", value)
+}
+
+// name returns a string property of an object field, e.g. the name of the status of an issue.
+func name(field interface{}, property string) string {
+	object, _ := field.(map[string]interface{})
+	value, _ := object[property].(string)
+	return value
+}
+
+// unquote trims the spaces and quotes around a JQL value.
+func unquote(value string) string {
+	return strings.Trim(strings.TrimSpace(value), `"'`)
+}
@@ -0,0 +1,124 @@
+package jiratest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/nclandrei/ticketguru/jira"
+)
+
+// responsesDir is the subdirectory of a fixture directory holding recorded responses.
+const responsesDir = "responses"
+
+// Fixture defines a recorded response to a GET request, replayed by the fake server for an identical request.
+type Fixture struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Query       string `json:"query,omitempty"`
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        string `json:"body"`
+}
+
+// Recorder wraps an http.RoundTripper and writes every GET response it receives into the responses of a fixture
+// directory, so that a live fetch can later be replayed offline. Requests to the session resource are not
+// recorded, and neither are request or response headers, so no credentials end up in the fixtures.
+type Recorder struct {
+	Base http.RoundTripper
+	Dir  string
+	lock sync.Mutex
+}
+
+// NewRecorder returns a new Recorder wrapping base and writing into the fixture directory dir.
+func NewRecorder(base http.RoundTripper, dir string) (*Recorder, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if err := os.MkdirAll(filepath.Join(dir, responsesDir), 0755); err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		Base: base,
+		Dir:  dir,
+	}, nil
+}
+
+// Record makes a Jira client record its responses into the fixture directory dir, above its retries.
+// It should be the last option applied, as jira.WithRetries replaces the transport.
+func Record(dir string) jira.ClientOption {
+	return func(client *jira.Client) (*jira.Client, error) {
+		recorder, err := NewRecorder(client.Transport, dir)
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = recorder
+		return client, nil
+	}
+}
+
+// RoundTrip performs the request through the base transport and records the response.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.Base.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet || strings.Contains(req.URL.Path, "/rest/auth/") {
+		return resp, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	fixture := Fixture{
+		Method:      req.Method,
+		Path:        req.URL.Path,
+		Query:       req.URL.Query().Encode(),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        string(body),
+	}
+	if err := r.save(fixture); err != nil {
+		return nil, fmt.Errorf("could not record response to %s: %v", req.URL.Path, err)
+	}
+	return resp, nil
+}
+
+// save writes a fixture into the responses of the fixture directory.
+func (r *Recorder) save(fixture Fixture) error {
+	content, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return ioutil.WriteFile(filepath.Join(r.Dir, responsesDir, fixtureFile(fixture)), content, 0644)
+}
+
+// fixtureKey returns the key a request is matched on when replaying fixtures.
+func fixtureKey(method, path string, query url.Values) string {
+	return method + " " + path + "?" + query.Encode()
+}
+
+// fixtureFile returns the name of the file a fixture is recorded in: its path, made file name safe,
+// followed by a hash of its key.
+func fixtureFile(fixture Fixture) string {
+	query, _ := url.ParseQuery(fixture.Query)
+	hash := sha256.Sum256([]byte(fixtureKey(fixture.Method, fixture.Path, query)))
+	name := strings.Trim(strings.Map(func(r rune) rune {
+		if ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') || r == '-' {
+			return r
+		}
+		return '_'
+	}, fixture.Path), "_")
+	return name + "-" + hex.EncodeToString(hash[:6]) + ".json"
+}
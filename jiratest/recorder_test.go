@@ -0,0 +1,54 @@
+package jiratest
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/nclandrei/ticketguru/jira"
+)
+
+func TestRecordReplay(t *testing.T) {
+	live, err := NewServer("testdata")
+	if err != nil {
+		t.Fatalf("could not start fake Jira: %v", err)
+	}
+	defer live.Close()
+	live.MaxEmbedded = 1
+
+	dir := t.TempDir()
+	client, err := live.JiraClient(Record(dir))
+	if err != nil {
+		t.Fatalf("could not create recording client: %v", err)
+	}
+	recorded, err := client.Tickets(context.Background(), jira.ProjectQuery("KAFKA"), 0, 50)
+	if err != nil {
+		t.Fatalf("could not fetch tickets: %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, responsesDir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The search, then the changelog, comments and worklogs of KAFKA-1 truncated by MaxEmbedded.
+	if len(files) != 4 {
+		t.Errorf("got %d recorded responses, want 4", len(files))
+	}
+
+	replay, err := NewServer(dir)
+	if err != nil {
+		t.Fatalf("could not start replaying fake Jira: %v", err)
+	}
+	defer replay.Close()
+	client, err = replay.JiraClient()
+	if err != nil {
+		t.Fatalf("could not create replaying client: %v", err)
+	}
+	replayed, err := client.Tickets(context.Background(), jira.ProjectQuery("KAFKA"), 0, 50)
+	if err != nil {
+		t.Fatalf("could not fetch replayed tickets: %v", err)
+	}
+	if !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("replayed tickets differ from the recorded ones")
+	}
+}
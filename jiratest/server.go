@@ -0,0 +1,395 @@
+// Package jiratest provides a fake Jira instance serving fixture files, along with a transport recording the
+// responses of a live instance into such fixtures, so that the fetch and store pipeline can run offline.
+//
+// A fixture directory holds issues/<KEY>.json files, each the JSON of an issue as returned by the single issue
+// endpoint with its changelog expanded, and a responses directory of recorded responses. Recorded responses
+// are replayed first; other requests are answered from the issues, for which the server implements search
+// with pagination over a subset of JQL and the paginated changelog, comment and worklog resources.
+package jiratest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nclandrei/ticketguru/jira"
+)
+
+// issuesDir is the subdirectory of a fixture directory holding issues.
+const issuesDir = "issues"
+
+// sessionCookie is the name of the cookie holding the session created by the fake server.
+const sessionCookie = "JSESSIONID"
+
+// Server defines a fake Jira instance serving the fixtures of a directory.
+type Server struct {
+	*httptest.Server
+	// ContextPath is the path Jira is mounted at, empty for root.
+	ContextPath string
+	// Username and Password, when set, are required to create a session or through basic auth.
+	Username string
+	Password string
+	// Token, when set, is accepted as a personal access token through bearer auth.
+	Token string
+	// MaxEmbedded caps the comments, worklogs and histories embedded in search results, leaving the rest to
+	// their paginated resources as Jira does; zero embeds everything.
+	MaxEmbedded int
+
+	issues    []issue
+	responses map[string]Fixture
+}
+
+// issue holds the decoded JSON of an issue fixture.
+type issue struct {
+	key  string
+	json map[string]interface{}
+}
+
+// NewServer starts a fake Jira instance serving the fixtures of dir.
+func NewServer(dir string) (*Server, error) {
+	s := &Server{
+		responses: make(map[string]Fixture),
+	}
+	if err := s.loadIssues(filepath.Join(dir, issuesDir)); err != nil {
+		return nil, fmt.Errorf("could not load issue fixtures: %v", err)
+	}
+	if err := s.loadResponses(filepath.Join(dir, responsesDir)); err != nil {
+		return nil, fmt.Errorf("could not load response fixtures: %v", err)
+	}
+	s.Server = httptest.NewServer(s)
+	return s, nil
+}
+
+// JiraClient returns a Jira client pointed at the fake instance, authenticating with the configured
+// credentials through a session unless options say otherwise.
+func (s *Server) JiraClient(options ...jira.ClientOption) (*jira.Client, error) {
+	u, err := url.Parse(s.URL + s.ContextPath)
+	if err != nil {
+		return nil, err
+	}
+	options = append([]jira.ClientOption{
+		jira.WithAuthenticator(&jira.SessionAuthenticator{Username: s.Username, Password: s.Password}),
+	}, options...)
+	return jira.NewClient(u, options...)
+}
+
+// loadIssues reads the issue fixtures of a directory, sorted by key; a missing directory holds no issues.
+func (s *Server) loadIssues(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		var fields map[string]interface{}
+		if err := readJSON(file, &fields); err != nil {
+			return err
+		}
+		key, _ := fields["key"].(string)
+		if key == "" {
+			key = strings.TrimSuffix(filepath.Base(file), ".json")
+			fields["key"] = key
+		}
+		s.issues = append(s.issues, issue{key: key, json: fields})
+	}
+	sort.Slice(s.issues, func(i, j int) bool {
+		return issueLess(s.issues[i].key, s.issues[j].key)
+	})
+	return nil
+}
+
+// loadResponses reads the recorded responses of a directory; a missing directory holds no responses.
+func (s *Server) loadResponses(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		var fixture Fixture
+		if err := readJSON(file, &fixture); err != nil {
+			return err
+		}
+		query, err := url.ParseQuery(fixture.Query)
+		if err != nil {
+			return fmt.Errorf("invalid query in %s: %v", file, err)
+		}
+		s.responses[fixtureKey(fixture.Method, fixture.Path, query)] = fixture
+	}
+	return nil
+}
+
+// ServeHTTP answers a request from the recorded responses or, failing that, from the issue fixtures.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, s.ContextPath)
+	if path == "/rest/auth/1/session" && r.Method == http.MethodPost {
+		s.createSession(w, r)
+		return
+	}
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if fixture, ok := s.responses[fixtureKey(r.Method, r.URL.Path, r.URL.Query())]; ok {
+		if fixture.ContentType != "" {
+			w.Header().Set("Content-Type", fixture.ContentType)
+		}
+		w.WriteHeader(fixture.Status)
+		w.Write([]byte(fixture.Body))
+		return
+	}
+
+	if r.Method != http.MethodGet || !strings.HasPrefix(path, "/rest/api/") {
+		http.NotFound(w, r)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(path, "/rest/api/"), "/")
+	switch {
+	case len(parts) == 2 && parts[1] == "serverInfo":
+		writeJSON(w, map[string]interface{}{"baseUrl": s.URL + s.ContextPath, "version": "8.0.0"})
+	case len(parts) == 2 && parts[1] == "myself":
+		writeJSON(w, map[string]interface{}{"name": s.Username, "active": true})
+	case len(parts) == 2 && parts[1] == "search":
+		s.search(w, r.URL.Query())
+	case len(parts) == 3 && parts[1] == "issue":
+		if i, ok := s.issue(parts[2]); ok {
+			writeJSON(w, i.json)
+			return
+		}
+		http.NotFound(w, r)
+	case len(parts) == 4 && parts[1] == "issue":
+		i, ok := s.issue(parts[2])
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		s.subresource(w, r.URL.Query(), i, parts[3])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// createSession checks the credentials of a session request and sets the session cookie.
+func (s *Server) createSession(w http.ResponseWriter, r *http.Request) {
+	var credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		http.Error(w, "invalid session request", http.StatusBadRequest)
+		return
+	}
+	if s.Username != "" && (credentials.Username != s.Username || credentials.Password != s.Password) {
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "jiratest", Path: "/"})
+	writeJSON(w, map[string]interface{}{
+		"session": map[string]string{"name": sessionCookie, "value": "jiratest"},
+	})
+}
+
+// authorized returns whether a request carries the session cookie, valid basic auth credentials or the
+// bearer token, when credentials are configured.
+func (s *Server) authorized(r *http.Request) bool {
+	if s.Username == "" && s.Token == "" {
+		return true
+	}
+	if s.Token != "" && r.Header.Get("Authorization") == "Bearer "+s.Token {
+		return true
+	}
+	if s.Username == "" {
+		return false
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil && cookie.Value == "jiratest" {
+		return true
+	}
+	username, password, ok := r.BasicAuth()
+	return ok && username == s.Username && password == s.Password
+}
+
+// search answers a paginated search over the issues selected by the JQL expression, rejecting expressions
+// outside the subset understood by parseJQL as Jira rejects invalid ones.
+func (s *Server) search(w http.ResponseWriter, query url.Values) {
+	clauses, err := parseJQL(query.Get("jql"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"errorMessages": []string{err.Error()}})
+		return
+	}
+	var matching []issue
+issues:
+	for _, i := range s.issues {
+		for _, c := range clauses {
+			if !c.matches(i) {
+				continue issues
+			}
+		}
+		matching = append(matching, i)
+	}
+
+	startAt, maxResults := pagination(query, 50)
+	var issues []interface{}
+	for k := startAt; k < len(matching) && k < startAt+maxResults; k++ {
+		issues = append(issues, s.embedded(matching[k], strings.Contains(query.Get("expand"), "changelog")))
+	}
+	writeJSON(w, map[string]interface{}{
+		"startAt":    startAt,
+		"maxResults": maxResults,
+		"total":      len(matching),
+		"issues":     issues,
+	})
+}
+
+// embedded returns an issue as embedded in search results: truncated to MaxEmbedded comments, worklogs
+// and histories, and without changelog unless expanded.
+func (s *Server) embedded(i issue, changelog bool) map[string]interface{} {
+	result := make(map[string]interface{}, len(i.json))
+	for k, v := range i.json {
+		result[k] = v
+	}
+	if fields, ok := i.json["fields"].(map[string]interface{}); ok {
+		copied := make(map[string]interface{}, len(fields))
+		for k, v := range fields {
+			copied[k] = v
+		}
+		copied["comment"] = s.truncate(fields["comment"], "comments")
+		copied["worklog"] = s.truncate(fields["worklog"], "worklogs")
+		result["fields"] = copied
+	}
+	if changelog {
+		result["changelog"] = s.truncate(i.json["changelog"], "histories")
+	} else {
+		delete(result, "changelog")
+	}
+	return result
+}
+
+// truncate caps the entries of a paginated container at MaxEmbedded, keeping their total.
+func (s *Server) truncate(container interface{}, entriesKey string) interface{} {
+	entries := list(container, entriesKey)
+	limit := len(entries)
+	if s.MaxEmbedded > 0 && s.MaxEmbedded < limit {
+		limit = s.MaxEmbedded
+	}
+	return map[string]interface{}{
+		"startAt":    0,
+		"maxResults": limit,
+		"total":      len(entries),
+		entriesKey:   entries[:limit],
+	}
+}
+
+// subresource answers a page of the changelog, comments or worklogs of an issue.
+func (s *Server) subresource(w http.ResponseWriter, query url.Values, i issue, name string) {
+	fields, _ := i.json["fields"].(map[string]interface{})
+	var entries []interface{}
+	var entriesKey string
+	switch name {
+	case "changelog":
+		entries, entriesKey = list(i.json["changelog"], "histories"), "values"
+	case "comment":
+		entries, entriesKey = list(fields["comment"], "comments"), "comments"
+	case "worklog":
+		entries, entriesKey = list(fields["worklog"], "worklogs"), "worklogs"
+	default:
+		http.Error(w, "unknown issue resource "+name, http.StatusNotFound)
+		return
+	}
+
+	startAt, maxResults := pagination(query, 100)
+	end := startAt + maxResults
+	if end > len(entries) {
+		end = len(entries)
+	}
+	if startAt > end {
+		startAt = end
+	}
+	writeJSON(w, map[string]interface{}{
+		"startAt":    startAt,
+		"maxResults": maxResults,
+		"total":      len(entries),
+		"isLast":     end == len(entries),
+		entriesKey:   entries[startAt:end],
+	})
+}
+
+// issue returns the issue fixture with a key or ID.
+func (s *Server) issue(keyOrID string) (issue, bool) {
+	for _, i := range s.issues {
+		if i.key == keyOrID || i.json["id"] == keyOrID {
+			return i, true
+		}
+	}
+	return issue{}, false
+}
+
+// list returns the entries of a paginated container, e.g. the comments of a comment field.
+func list(container interface{}, entriesKey string) []interface{} {
+	object, _ := container.(map[string]interface{})
+	entries, _ := object[entriesKey].([]interface{})
+	return entries
+}
+
+// pagination returns the startAt and maxResults parameters of a query.
+func pagination(query url.Values, defaultMaxResults int) (int, int) {
+	startAt, _ := strconv.Atoi(query.Get("startAt"))
+	maxResults, err := strconv.Atoi(query.Get("maxResults"))
+	if err != nil || maxResults < 0 {
+		maxResults = defaultMaxResults
+	}
+	if startAt < 0 {
+		startAt = 0
+	}
+	return startAt, maxResults
+}
+
+// issueLess orders issue keys by project, then numerically by issue number.
+func issueLess(a, b string) bool {
+	aProject, aNumber := splitKey(a)
+	bProject, bNumber := splitKey(b)
+	if aProject != bProject {
+		return aProject < bProject
+	}
+	return aNumber < bNumber
+}
+
+// splitKey splits an issue key into its project and number.
+func splitKey(key string) (string, int) {
+	dash := strings.LastIndex(key, "-")
+	number, _ := strconv.Atoi(key[dash+1:])
+	return key[:dash+1], number
+}
+
+// readJSON decodes a JSON file into v, keeping numbers as they are written.
+func readJSON(path string, v interface{}) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("could not decode %s: %v", path, err)
+	}
+	return nil
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
@@ -271,6 +271,39 @@ func SentimentAnalysis(tickets ...jira.JiraIssue) error {
 	)
 }
 
+// CustomField returns a plot drawing a scatter plot of the numeric values of a mapped custom field
+// (e.g. story points) against times-to-close.
+func CustomField(name string) Plot {
+	return func(tickets ...jira.JiraIssue) error {
+		var values []float64
+		var times []float64
+		for _, ticket := range tickets {
+			highPriority := jira.IsHighPriority(ticket)
+			value, ok := ticket.CustomFloat(name)
+			if highPriority &&
+				ticket.TimeToClose > 0 &&
+				ticket.TimeToClose <= jira.MaxTimeToCloseH &&
+				ok {
+				values = append(values, value)
+				times = append(times, ticket.TimeToClose)
+			}
+		}
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		filePath := fmt.Sprintf("%s/%s/custom_%s.png", wd, graphsFolder, name)
+		return scatter(
+			name,
+			"Time-To-Close (hours)",
+			fmt.Sprintf("%s Analysis", name),
+			filePath,
+			values,
+			times,
+		)
+	}
+}
+
 // barchart computes and saves a barchart given a variadic number of bars.
 func barchart(title, yAxis, filepath string, vals map[string]float64) error {
 	var bars []chart.Value
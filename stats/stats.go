@@ -5,6 +5,7 @@ import (
 	"github.com/dgryski/go-onlinestats"
 	"github.com/nclandrei/ticketguru/jira"
 	"math"
+	"strings"
 )
 
 // Stats defines the basic slice of float64 used for statistical tests.
@@ -178,6 +179,139 @@ func Grammar(tickets ...jira.JiraIssue) *SpearmanResult {
 	return twoSampleSpearmanRTest(scores, times)
 }
 
+// LinksCount performs Spearman R's test on the number of links of tickets and times-to-close.
+func LinksCount(tickets ...jira.JiraIssue) *SpearmanResult {
+	var links stats
+	var times stats
+	for _, t := range tickets {
+		highPriority := jira.IsHighPriority(t)
+		if highPriority &&
+			t.TimeToClose > 0 &&
+			t.TimeToClose <= jira.MaxTimeToCloseH {
+			links = append(links, float64(len(t.Fields.IssueLinks)))
+			times = append(times, t.TimeToClose)
+		}
+	}
+	return twoSampleSpearmanRTest(links, times)
+}
+
+// BlockedDuration performs Spearman R's test on the hours tickets spent blocked and times-to-close.
+func BlockedDuration(tickets ...jira.JiraIssue) *SpearmanResult {
+	var blocked stats
+	var times stats
+	for _, t := range tickets {
+		highPriority := jira.IsHighPriority(t)
+		if highPriority &&
+			t.TimeToClose > 0 &&
+			t.TimeToClose <= jira.MaxTimeToCloseH &&
+			t.BlockedHours > 0 {
+			blocked = append(blocked, t.BlockedHours)
+			times = append(times, t.TimeToClose)
+		}
+	}
+	return twoSampleSpearmanRTest(blocked, times)
+}
+
+// Links performs Welch's T Test on the presence of links of a given type (e.g. Duplicate, Blocks) for all
+// tickets, or of any link if no type is given.
+func Links(linkType string) CategoricalTest {
+	return func(tickets ...jira.JiraIssue) (*TTestResult, error) {
+		var withTimes stats
+		var withoutTimes stats
+		for _, t := range tickets {
+			highPriority := jira.IsHighPriority(t)
+			if t.TimeToClose <= 0 ||
+				t.TimeToClose > jira.MaxTimeToCloseH ||
+				!highPriority {
+				continue
+			}
+			var linked bool
+			for _, link := range t.Fields.IssueLinks {
+				if linkType == "" || strings.EqualFold(link.Type.Name, linkType) {
+					linked = true
+					break
+				}
+			}
+			if linked {
+				withTimes = append(withTimes, t.TimeToClose)
+			} else {
+				withoutTimes = append(withoutTimes, t.TimeToClose)
+			}
+		}
+		return twoSampleWelchTTest(withTimes, withoutTimes)
+	}
+}
+
+// EffortContributors performs Spearman R's test on the number of people logging work and times-to-close.
+func EffortContributors(tickets ...jira.JiraIssue) *SpearmanResult {
+	return effortTest(func(e jira.Effort) float64 { return float64(e.Contributors) }, tickets...)
+}
+
+// EffortSessions performs Spearman R's test on the number of work sessions and times-to-close.
+func EffortSessions(tickets ...jira.JiraIssue) *SpearmanResult {
+	return effortTest(func(e jira.Effort) float64 { return float64(e.Sessions) }, tickets...)
+}
+
+// EffortRatio performs Spearman R's test on the ratio of logged to calendar time and times-to-close.
+func EffortRatio(tickets ...jira.JiraIssue) *SpearmanResult {
+	return effortTest(func(e jira.Effort) float64 { return e.Ratio }, tickets...)
+}
+
+// effortTest performs Spearman R's test on an effort metric and times-to-close for tickets with logged work.
+func effortTest(metric func(jira.Effort) float64, tickets ...jira.JiraIssue) *SpearmanResult {
+	var values stats
+	var times stats
+	for _, t := range tickets {
+		highPriority := jira.IsHighPriority(t)
+		if highPriority &&
+			t.TimeToClose > 0 &&
+			t.TimeToClose <= jira.MaxTimeToCloseH &&
+			t.Effort.HasEffort {
+			values = append(values, metric(t.Effort))
+			times = append(times, t.TimeToClose)
+		}
+	}
+	return twoSampleSpearmanRTest(values, times)
+}
+
+// SprintCarryOvers performs Spearman R's test on the number of sprint carry-overs and times-to-close.
+func SprintCarryOvers(tickets ...jira.JiraIssue) *SpearmanResult {
+	var carryOvers stats
+	var times stats
+	for _, t := range tickets {
+		highPriority := jira.IsHighPriority(t)
+		if highPriority &&
+			t.TimeToClose > 0 &&
+			t.TimeToClose <= jira.MaxTimeToCloseH &&
+			t.SprintMetrics.HasSprints {
+			carryOvers = append(carryOvers, float64(t.SprintMetrics.CarryOvers))
+			times = append(times, t.TimeToClose)
+		}
+	}
+	return twoSampleSpearmanRTest(carryOvers, times)
+}
+
+// CustomField returns a test performing Spearman R's test on the numeric values of a mapped custom
+// field (e.g. story points) and times-to-close.
+func CustomField(name string) ContinuousTest {
+	return func(tickets ...jira.JiraIssue) *SpearmanResult {
+		var values stats
+		var times stats
+		for _, t := range tickets {
+			highPriority := jira.IsHighPriority(t)
+			value, ok := t.CustomFloat(name)
+			if highPriority &&
+				t.TimeToClose > 0 &&
+				t.TimeToClose <= jira.MaxTimeToCloseH &&
+				ok {
+				values = append(values, value)
+				times = append(times, t.TimeToClose)
+			}
+		}
+		return twoSampleSpearmanRTest(values, times)
+	}
+}
+
 // twoSampleSpearmanRTest returns the rank correlation coefficient and p value given two samples.
 func twoSampleSpearmanRTest(xs, ys stats) *SpearmanResult {
 	rs, p := onlinestats.Spearman(xs, ys)
@@ -12,6 +12,9 @@ const (
 	// Custom time format corresponding to Jira format.
 	timeFormat = "2006-01-02T15:04:05.000-0700"
 
+	// JQLTimeFormat is the date time format accepted by JQL date comparisons.
+	JQLTimeFormat = "2006/01/02 15:04"
+
 	// MaxTimeToCloseH represents the maximum number of hours until ticket closing allowed in analysis, plotting and stats.
 	MaxTimeToCloseH = 27000
 
@@ -39,6 +42,9 @@ func (t *Time) UnmarshalJSON(b []byte) error {
 	}
 
 	jiraTime, err := time.Parse(timeFormat, s)
+	if err != nil {
+		jiraTime, err = time.Parse(time.RFC3339Nano, s)
+	}
 	if err != nil {
 		jiraTime, err = time.Parse("2006-01-02", s)
 		if err != nil {
@@ -72,6 +78,21 @@ type JiraIssue struct {
 	HasStepsToReproduce   bool
 	SummaryDescWordsCount int
 	CommentWordsCount     int
+	// Stale marks tickets fetched or updated since the derived analysis fields were last computed.
+	Stale bool
+	// Analyses lists the analyses attempted on a stale ticket since it was last marked stale.
+	Analyses []string `json:"analyses,omitempty"`
+	Effort   Effort
+	// BlockedHours is the number of hours the ticket spent blocked by other tickets or in a blocked status.
+	BlockedHours float64
+	// Sprints holds the sprints the ticket has been in, ordered by start date.
+	Sprints       []Sprint `json:"sprints,omitempty"`
+	SprintMetrics SprintMetrics
+	// CustomFields holds the raw values of the fields declared in the field mapping, keyed by logical name.
+	CustomFields map[string]json.RawMessage `json:"customFields,omitempty"`
+	// Instance and Project name the tracker instance and the project of the crawl manifest the ticket was fetched for.
+	Instance string `json:"instance,omitempty"`
+	Project  string `json:"project,omitempty"`
 }
 
 // Sentiment holds information regarding the sentiment analysis score and if the analysis has been conducted.
@@ -80,6 +101,41 @@ type Sentiment struct {
 	HasScore bool
 }
 
+// Effort holds the metrics derived from the worklogs of a ticket and if they have been computed.
+type Effort struct {
+	// Contributors is the number of distinct people who logged work.
+	Contributors int
+	// Sessions is the number of work sessions, i.e. runs of worklogs separated by short gaps.
+	Sessions int
+	// LoggedHours is the total number of hours logged.
+	LoggedHours float64
+	// Ratio is the ratio between the logged hours and the calendar hours from creation to closing.
+	Ratio     float64
+	HasEffort bool
+}
+
+// Sprint defines a Jira Software sprint, as returned by the Agile REST API.
+type Sprint struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	State         string `json:"state"`
+	StartDate     Time   `json:"startDate,omitempty"`
+	EndDate       Time   `json:"endDate,omitempty"`
+	CompleteDate  Time   `json:"completeDate,omitempty"`
+	OriginBoardID int    `json:"originBoardId,omitempty"`
+}
+
+// SprintMetrics holds the metrics derived from the sprints of a ticket and if they have been computed.
+type SprintMetrics struct {
+	// Count is the number of sprints the ticket has been in.
+	Count int
+	// CarryOvers is the number of times the ticket was moved, unfinished, from a completed sprint to another.
+	CarryOvers int
+	// Hours is the number of hours from the start of the first sprint to the end of the last one.
+	Hours      float64
+	HasSprints bool
+}
+
 // GrammarCorrectness holds information regarding the grammar correctness score and if the analysis has been conducted.
 type GrammarCorrectness struct {
 	Score    int
@@ -88,17 +144,24 @@ type GrammarCorrectness struct {
 
 // Fields defines the fields retrieved via the REST API
 type Fields struct {
-	Summary      string       `json:"summary"`
-	Description  string       `json:"description,omitempty"`
-	TimeEstimate int          `json:"timeestimate,omitempty"`
-	TimeSpent    int          `json:"timespent,omitempty"`
-	Created      Time         `json:"created"`
-	Attachments  []Attachment `json:"attachment,omitempty"`
-	Status       Status       `json:"status,omitempty"`
-	DueDate      Time         `json:"duedate,omitempty"`
-	Comments     Comments     `json:"comment,omitempty"`
-	Priority     Priority     `json:"priority,omitempty"`
-	Type         Type         `json:"issuetype,omitempty"`
+	Summary      string        `json:"summary"`
+	Description  string        `json:"description,omitempty"`
+	TimeEstimate int           `json:"timeestimate,omitempty"`
+	TimeSpent    int           `json:"timespent,omitempty"`
+	Created      Time          `json:"created"`
+	Attachments  []Attachment  `json:"attachment,omitempty"`
+	Status       Status        `json:"status,omitempty"`
+	DueDate      Time          `json:"duedate,omitempty"`
+	Comments     Comments      `json:"comment,omitempty"`
+	Priority     Priority      `json:"priority,omitempty"`
+	Type         Type          `json:"issuetype,omitempty"`
+	Labels       []string      `json:"labels,omitempty"`
+	Worklogs     Worklogs      `json:"worklog,omitempty"`
+	IssueLinks   []IssueLink   `json:"issuelinks,omitempty"`
+	Subtasks     []LinkedIssue `json:"subtasks,omitempty"`
+	Parent       *LinkedIssue  `json:"parent,omitempty"`
+	// DescriptionStructure holds the structural hints of an ADF description (v3 API only).
+	DescriptionStructure Structure `json:"descriptionStructure,omitempty"`
 }
 
 // TicketKey returns the unique key of a Jira issue.
@@ -115,6 +178,64 @@ func (t *JiraIssue) TicketBody() ([]byte, error) {
 	return res, nil
 }
 
+// CustomField decodes the value of a mapped custom field into v, returning false if the field is not set.
+func (t *JiraIssue) CustomField(name string, v interface{}) (bool, error) {
+	raw, ok := t.CustomFields[name]
+	if !ok || string(raw) == "null" {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+// CustomFloat returns the numeric value of a mapped custom field (e.g. story points), which Jira may encode
+// either as a number or as a string.
+func (t *JiraIssue) CustomFloat(name string) (float64, bool) {
+	var f float64
+	if ok, err := t.CustomField(name, &f); ok && err == nil {
+		return f, true
+	}
+	var s string
+	if ok, err := t.CustomField(name, &s); ok && err == nil {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// CustomStrings returns the textual values of a mapped custom field, flattening arrays and the value/name
+// objects Jira uses for select lists, components, versions and sprints.
+func (t *JiraIssue) CustomStrings(name string) []string {
+	var values []json.RawMessage
+	if ok, err := t.CustomField(name, &values); !ok {
+		return nil
+	} else if err != nil {
+		values = []json.RawMessage{t.CustomFields[name]}
+	}
+	var result []string
+	for _, v := range values {
+		var s string
+		if err := json.Unmarshal(v, &s); err == nil {
+			result = append(result, s)
+			continue
+		}
+		var object struct {
+			Value string `json:"value"`
+			Name  string `json:"name"`
+		}
+		if err := json.Unmarshal(v, &object); err == nil {
+			if object.Value != "" {
+				result = append(result, object.Value)
+			} else if object.Name != "" {
+				result = append(result, object.Name)
+			}
+			continue
+		}
+		result = append(result, string(v))
+	}
+	return result
+}
+
 // Changelog defines the entire changelog of a Jira ticket.
 type Changelog struct {
 	StartAt    int                `json:"startAt"`
@@ -134,6 +255,7 @@ type ChangelogHistory struct {
 // Author holds the author for any jira ticket field.
 type Author struct {
 	Name        string `json:"name,omitempty"`
+	AccountID   string `json:"accountId,omitempty"`
 	Email       string `json:"emailAddress,omitempty"`
 	DisplayName string `json:"displayName,omitempty"`
 	Active      bool   `json:"active,omitempty"`
@@ -160,6 +282,9 @@ type Attachment struct {
 	MimeType string         `json:"mimeType,omitempty"`
 	Content  string         `json:"content,omitempty"`
 	Type     AttachmentType `json:"attachment_type,omitempty"`
+	// SHA256 and ContentSize are set once the body has been downloaded into the local attachment store.
+	SHA256      string `json:"sha256,omitempty"`
+	ContentSize int64  `json:"contentSize,omitempty"`
 }
 
 // AttachmentType maps the extension of the attachment to a predefined type (e.g. image).
@@ -205,9 +330,61 @@ type Status struct {
 	StatusCategory struct{} `json:"-"`
 }
 
-// Comments defines the Jira field that holds the comments.
+// Worklogs defines the Jira field that holds the worklogs; Total is the number of worklogs reported by Jira,
+// which search results may truncate.
+type Worklogs struct {
+	Worklogs   []Worklog `json:"worklogs,omitempty"`
+	StartAt    int       `json:"startAt,omitempty"`
+	MaxResults int       `json:"maxResults,omitempty"`
+	Total      int       `json:"total,omitempty"`
+}
+
+// Worklog defines a unit of work logged on a Jira ticket.
+type Worklog struct {
+	ID               string `json:"id,omitempty"`
+	Author           Author `json:"author"`
+	Comment          string `json:"comment,omitempty"`
+	Started          Time   `json:"started,omitempty"`
+	TimeSpentSeconds int    `json:"timeSpentSeconds,omitempty"`
+}
+
+// IssueLink defines a link between two Jira tickets; exactly one of InwardIssue and OutwardIssue is set,
+// depending on the direction of the link relative to the ticket holding it.
+type IssueLink struct {
+	ID           string        `json:"id,omitempty"`
+	Type         IssueLinkType `json:"type"`
+	InwardIssue  *LinkedIssue  `json:"inwardIssue,omitempty"`
+	OutwardIssue *LinkedIssue  `json:"outwardIssue,omitempty"`
+}
+
+// IssueLinkType defines the type of a link (e.g. Blocks), along with its inward ("is blocked by")
+// and outward ("blocks") descriptions.
+type IssueLinkType struct {
+	ID      string `json:"id,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Inward  string `json:"inward,omitempty"`
+	Outward string `json:"outward,omitempty"`
+}
+
+// LinkedIssue defines the summary of a ticket referenced by a link, a subtask or a parent relation.
+type LinkedIssue struct {
+	ID     string `json:"id,omitempty"`
+	Key    string `json:"key,omitempty"`
+	Fields struct {
+		Summary  string   `json:"summary,omitempty"`
+		Status   Status   `json:"status,omitempty"`
+		Priority Priority `json:"priority,omitempty"`
+		Type     Type     `json:"issuetype,omitempty"`
+	} `json:"fields"`
+}
+
+// Comments defines the Jira field that holds the comments; Total is the number of comments reported by Jira,
+// which search results may truncate.
 type Comments struct {
-	Comments []Comment `json:"comments,omitempty"`
+	Comments   []Comment `json:"comments,omitempty"`
+	StartAt    int       `json:"startAt,omitempty"`
+	MaxResults int       `json:"maxResults,omitempty"`
+	Total      int       `json:"total,omitempty"`
 }
 
 // Comment defines the structure of a Jira ticket comment.
@@ -217,6 +394,62 @@ type Comment struct {
 	Author  Author `json:"author"`
 	Created Time   `json:"created,omitempty"`
 	Updated Time   `json:"updated,omitempty"`
+	// Structure holds the structural hints of an ADF body (v3 API only).
+	Structure Structure `json:"structure,omitempty"`
+}
+
+// MarkStale flags the derived analysis fields of a ticket as outdated, to be recomputed by every analysis.
+func (t *JiraIssue) MarkStale() {
+	t.Stale = true
+	t.Analyses = nil
+}
+
+// Analyzed returns whether an analysis is up to date on a ticket: the ticket is not stale, or the analysis
+// has been attempted since it was last marked stale.
+func (t *JiraIssue) Analyzed(analysis string) bool {
+	if !t.Stale {
+		return true
+	}
+	for _, a := range t.Analyses {
+		if a == analysis {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkAnalyzed records that an analysis has been attempted on a stale ticket, whether or not it could compute
+// a result, and clears the stale flag once every one of the analyses given has been attempted.
+func (t *JiraIssue) MarkAnalyzed(analysis string, all []string) {
+	if t.Analyzed(analysis) {
+		return
+	}
+	t.Analyses = append(t.Analyses, analysis)
+	for _, a := range all {
+		if !t.Analyzed(a) {
+			return
+		}
+	}
+	t.Stale = false
+	t.Analyses = nil
+}
+
+// ProjectKey returns the project a ticket key belongs to: KAFKA for KAFKA-1, BZ for BZ-1 and owner/repo
+// for owner/repo#1.
+func ProjectKey(key string) string {
+	if i := strings.LastIndex(key, "#"); i > 0 {
+		return key[:i]
+	}
+	if i := strings.LastIndex(key, "-"); i > 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// IsClosingStatus returns whether a ticket moving into a status means it has been closed.
+func IsClosingStatus(status string) bool {
+	return status == "Closed" || status == "Resolved" || status == "Done" || status == "Completed" ||
+		status == "Fixed"
 }
 
 // IsHighPriority returns whether a ticket is of high priority or not.
@@ -228,6 +461,61 @@ func IsHighPriority(t JiraIssue) bool {
 	return pID <= 4
 }
 
+// DefaultPriorityLabels maps normalized priority names and P-levels, as used by Bugzilla and by label-based
+// trackers (e.g. GitHub, GitLab), onto Jira priority IDs. P0 and P1 are the most urgent levels, and every
+// low priority name maps onto an ID above the cutoff of IsHighPriority.
+var DefaultPriorityLabels = map[string]string{
+	"p0":       "1",
+	"p1":       "1",
+	"highest":  "1",
+	"blocker":  "1",
+	"urgent":   "1",
+	"p2":       "2",
+	"high":     "2",
+	"critical": "2",
+	"p3":       "3",
+	"medium":   "3",
+	"normal":   "3",
+	"major":    "3",
+	"p4":       "5",
+	"p5":       "5",
+	"low":      "5",
+	"minor":    "5",
+	"lowest":   "5",
+	"trivial":  "5",
+}
+
+// PriorityFromLabels derives a Jira priority from a set of labels; the most urgent matching label wins.
+// Labels qualify either when they are a bare priority (e.g. "P1", "critical") or when they are
+// namespaced with priority/severity (e.g. "priority: high", "severity::major").
+func PriorityFromLabels(labels []string, mapping map[string]string) Priority {
+	var best Priority
+	for _, l := range labels {
+		tokens := strings.FieldsFunc(strings.ToLower(l), func(r rune) bool {
+			return r == ':' || r == '/' || r == '-' || r == '_' || r == ' '
+		})
+		if len(tokens) == 0 {
+			continue
+		}
+		candidates := tokens
+		if len(tokens) > 1 {
+			switch tokens[0] {
+			case "priority", "pri", "severity", "sev":
+				candidates = tokens[1:]
+			default:
+				continue
+			}
+		}
+		for _, token := range candidates {
+			id, ok := mapping[token]
+			if ok && (best.ID == "" || id < best.ID) {
+				best = Priority{ID: id, Name: l}
+			}
+		}
+	}
+	return best
+}
+
 // Ticket describes a general interface for either Jira issues or Bugzilla tickets.
 type Ticket interface {
 	TicketKey() string